@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/credentials"
+	"golang.org/x/term"
+)
+
+const defaultHost = "github.com"
+
+// runAuth manages the API key used to talk to the integration remote
+func runAuth(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: ctrls auth login|logout|status [--host <host>]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "login":
+		authLogin(args[1:])
+	case "logout":
+		authLogout(args[1:])
+	case "status":
+		authStatus(args[1:])
+	default:
+		fmt.Printf("Unknown auth command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func authLogin(args []string) {
+	flags := flag.NewFlagSet("auth login", flag.ExitOnError)
+	host := flags.String("host", defaultHost, "host the API key is for")
+	store := flags.String("store", os.Getenv(credentials.StoreEnv), "where to keep the API key: git, for git's credential helper and the default when one is configured, or file, encrypted with a passphrase from $"+credentials.PassphraseEnv+" or the terminal. The passphrase keeps the key safe in copies and backups of the file, not from other programs you run.")
+	username := flags.String("username", "", "user name to send along with the API key")
+	fromStdin := flags.Bool("token-stdin", false, "read the API key from standard input")
+	flags.Parse(args)
+
+	target, err := credentials.New(*store, credentialsPassphrase)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	token, err := readToken(*host, *fromStdin)
+	if err != nil {
+		fmt.Println("Error reading API key:", err)
+		os.Exit(1)
+	}
+
+	err = target.Set(*host, credentials.Credential{Username: *username, Secret: token})
+	if err != nil {
+		fmt.Println("Error saving API key:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Saved API key for %s in %s\n", *host, target.Name())
+}
+
+func authLogout(args []string) {
+	flags := flag.NewFlagSet("auth logout", flag.ExitOnError)
+	host := flags.String("host", defaultHost, "host to forget the API key for")
+	flags.Parse(args)
+
+	store := credentialStore()
+	err := store.Delete(*host)
+	if err != nil {
+		fmt.Println("Error removing API key:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Removed saved API keys for %s\n", *host)
+	if cred, from, err := store.Find(*host); err == nil && cred.Secret != "" {
+		fmt.Printf("An API key is still provided by the %s\n", from.Name())
+	}
+}
+
+func authStatus(args []string) {
+	flags := flag.NewFlagSet("auth status", flag.ExitOnError)
+	host := flags.String("host", defaultHost, "host to show the API key for")
+	flags.Parse(args)
+
+	cred, from, err := credentialStore().Find(*host)
+	if errors.Is(err, credentials.ErrNotFound) {
+		fmt.Printf("Not logged in to %s. Run `ctrls auth login`.\n", *host)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Println("Error reading API key:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Logged in to %s as %s\n", *host, cred.UsernameOrDefault())
+	fmt.Printf("API key: %s\n", maskSecret(cred.Secret))
+	fmt.Printf("Stored in: %s\n", from.Name())
+}
+
+// credentialStore returns the credential lookup chain, first moving any
+// apikey.txt left by older versions into it
+func credentialStore() *credentials.Chain {
+	store, err := credentials.Default(credentialsPassphrase)
+	if err != nil {
+		fmt.Println("Error opening credential store:", err)
+		os.Exit(1)
+	}
+
+	moved, err := credentials.MigrateLegacyKeyFile(credentials.LegacyKeyFile, defaultHost, store)
+	if err != nil {
+		fmt.Printf("Error moving %s into the credential store: %v\n", credentials.LegacyKeyFile, err)
+		os.Exit(1)
+	}
+	if moved {
+		fmt.Printf("Moved the API key from %s into the %s\n", credentials.LegacyKeyFile, store.Writable.Name())
+	}
+
+	return store
+}
+
+// credentialsPassphrase asks for the passphrase of the credentials file
+func credentialsPassphrase(confirm bool) (string, error) {
+	return readPassphrase(credentials.PassphraseEnv, "Passphrase for the credentials file: ", confirm)
+}
+
+// readToken reads an API key from stdin or, on a terminal, without echoing it
+func readToken(host string, fromStdin bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if fromStdin || !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return nonEmptyToken(line)
+	}
+
+	fmt.Printf("API key for %s: ", host)
+	raw, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return nonEmptyToken(string(raw))
+}
+
+func nonEmptyToken(token string) (string, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", errors.New("empty API key")
+	}
+	return token, nil
+}
+
+// maskSecret shows just enough of a secret to recognise it
+func maskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("•", len(secret))
+	}
+	return secret[:4] + strings.Repeat("•", 8) + secret[len(secret)-4:]
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// runChangeset lists, shows and restores the changesets files were saved in
+func runChangeset(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: ctrls changeset list|show|restore")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		changesetList(args[1:])
+	case "show":
+		changesetShow(args[1:])
+	case "restore":
+		changesetRestore(args[1:])
+	default:
+		fmt.Printf("Unknown changeset command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// changesetList prints every changeset, newest first
+func changesetList(args []string) {
+	flags := flag.NewFlagSet("changeset list", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print changesets as JSON")
+	flags.Parse(args)
+
+	_, vRepo := openRepositories()
+
+	changesets, err := vRepo.Changesets()
+	if err != nil {
+		fmt.Println("Error reading changesets:", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		printJSON(changesets)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, c := range changesets {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			c.ID,
+			c.When.Local().Format("2006-01-02 15:04:05"),
+			c.Author,
+			changesetCount(c),
+			strings.Join(append(append([]string{}, c.Saved...), c.Deleted...), ", "),
+		)
+	}
+	w.Flush()
+}
+
+// changesetShow prints the files saved and deleted in a changeset
+func changesetShow(args []string) {
+	flags := flag.NewFlagSet("changeset show", flag.ExitOnError)
+	at := flags.String("at", "", "show the last changeset saved by this time, e.g. \"2h ago\" or \"2024-05-01 14:32\"")
+	asJSON := flags.Bool("json", false, "print the versions in the changeset as JSON")
+	names := parseInterspersed(flags, args)
+
+	if len(names) > 1 || (len(names) == 1) == (*at != "") {
+		fmt.Println("Usage: ctrls changeset show <id> | --at <time> [--json]")
+		os.Exit(1)
+	}
+
+	_, vRepo := openRepositories()
+
+	changeset := selectChangeset(vRepo, names, *at)
+	versions, err := vRepo.ChangesetVersions(changeset, false)
+	if err != nil {
+		fmt.Println("Error reading changeset:", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		printJSON(versions)
+		return
+	}
+
+	fmt.Printf("Changeset %s (%s)\n", changeset.ID, changeset.Hash[:7])
+	fmt.Printf("Saved %s by %s\n\n", changeset.When.Local().Format("2006-01-02 15:04:05"), changeset.Author)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, v := range versions {
+		fmt.Fprintf(w, "  saved\t%s\t%s\n", v.Path, utils.FormatSize(v.Size))
+	}
+	for _, path := range changeset.Deleted {
+		fmt.Fprintf(w, "  deleted\t%s\t\n", path)
+	}
+	w.Flush()
+}
+
+// changesetRestore writes the files of a changeset back into the project
+func changesetRestore(args []string) {
+	flags := flag.NewFlagSet("changeset restore", flag.ExitOnError)
+	at := flags.String("at", "", "restore the last changeset saved by this time, e.g. \"2h ago\" or \"2024-05-01 14:32\"")
+	all := flags.Bool("all", false, "restore every file as it was when the changeset was saved, not only the files saved in it")
+	dryRun := flags.Bool("dry-run", false, "list the files that would be restored without writing them")
+	force := flags.Bool("force", false, "save unsaved changes as new versions and restore anyway")
+	names := parseInterspersed(flags, args)
+
+	if len(names) > 1 || (len(names) == 1) == (*at != "") {
+		fmt.Println("Usage: ctrls changeset restore <id> | --at <time> [--all] [--dry-run] [--force]")
+		os.Exit(1)
+	}
+
+	repo, vRepo := openRepositories()
+
+	changeset := selectChangeset(vRepo, names, *at)
+	versions, err := vRepo.ChangesetVersions(changeset, *all)
+	if err != nil {
+		fmt.Println("Error reading changeset:", err)
+		os.Exit(1)
+	}
+
+	rootPath, err := repo.GetRepoRoot()
+	if err != nil {
+		fmt.Println("You are not in the root of the Git repository.")
+		os.Exit(1)
+	}
+
+	if *dryRun {
+		for _, v := range versions {
+			fmt.Printf("Would restore %s (%s)\n", v.Path, utils.FormatSize(v.Size))
+		}
+		for _, path := range changeset.Deleted {
+			fmt.Printf("Would leave %s alone, it was deleted in this changeset\n", path)
+		}
+		return
+	}
+
+	// Check every file before writing any, so a changeset is never left
+	// half restored. Paths come from the changeset, which may have been
+	// pulled from a shared remote.
+	dsts := make([]string, len(versions))
+	for i, v := range versions {
+		dsts[i], err = utils.ProjectPath(rootPath, v.Path)
+		if err != nil {
+			fmt.Printf("Refusing to restore %s: %v\n", v.Path, err)
+			os.Exit(1)
+		}
+	}
+	vRepo.BeginChangeset()
+	for i, v := range versions {
+		saveUnsaved(repo, vRepo, v.Path, dsts[i], *force)
+	}
+	commitChangeset(vRepo)
+
+	for i, v := range versions {
+		err := vRepo.WriteVersion(v, dsts[i])
+		if err != nil {
+			fmt.Printf("Error restoring %s: %v\n", v.Path, err)
+			os.Exit(1)
+		}
+		fmt.Println("Restored", v.Path)
+	}
+	for _, path := range changeset.Deleted {
+		fmt.Printf("Left %s alone, it was deleted in this changeset\n", path)
+	}
+
+	fmt.Printf("Restored %d file(s) from changeset %s\n", len(versions), changeset.ID)
+}
+
+// selectChangeset finds the changeset named by names or saved by the time
+// described by at
+func selectChangeset(vRepo *repository.Repository, names []string, at string) repository.Changeset {
+	changesets, err := vRepo.Changesets()
+	if err != nil {
+		fmt.Println("Error reading changesets:", err)
+		os.Exit(1)
+	}
+
+	var changeset repository.Changeset
+	if at != "" {
+		var t time.Time
+		t, err = utils.ParseTime(at, time.Now())
+		if err == nil {
+			changeset, err = repository.ChangesetAt(changesets, t)
+		}
+	} else {
+		changeset, err = repository.FindChangeset(changesets, names[0])
+	}
+	if err != nil {
+		fmt.Println("Error finding changeset:", err)
+		os.Exit(1)
+	}
+	return changeset
+}
+
+// changesetCount describes how many files a changeset touched
+func changesetCount(c repository.Changeset) string {
+	count := fmt.Sprintf("%d saved", len(c.Saved))
+	if len(c.Deleted) > 0 {
+		count += fmt.Sprintf(", %d deleted", len(c.Deleted))
+	}
+	return count
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// runCheckpoint takes a checkpoint of the whole worktree, or lists, shows
+// and restores checkpoints
+func runCheckpoint(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			checkpointList(args[1:])
+			return
+		case "show":
+			checkpointShow(args[1:])
+			return
+		case "restore":
+			checkpointRestore(args[1:])
+			return
+		}
+	}
+
+	flags := flag.NewFlagSet("checkpoint", flag.ExitOnError)
+	message := flags.String("m", "", "describe the checkpoint")
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		fmt.Println("Usage: ctrls checkpoint [-m <message>] | list | show [<id>] | restore [<id>]")
+		os.Exit(1)
+	}
+
+	repo, vRepo := openRepositories()
+
+	checkpoint, err := vRepo.CreateCheckpoint(*repo, *message)
+	if err != nil {
+		fmt.Println("Error creating checkpoint:", err)
+		os.Exit(1)
+	}
+	printCheckpointCreated(checkpoint)
+}
+
+// checkpointList prints every checkpoint, newest first
+func checkpointList(args []string) {
+	flags := flag.NewFlagSet("checkpoint list", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print checkpoints as JSON")
+	flags.Parse(args)
+
+	_, vRepo := openRepositories()
+
+	checkpoints, err := vRepo.Checkpoints()
+	if err != nil {
+		fmt.Println("Error reading checkpoints:", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		printJSON(checkpoints)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, c := range checkpoints {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			c.ID,
+			c.When.Local().Format("2006-01-02 15:04:05"),
+			c.Author,
+			shortHash(c.Head),
+			checkpointCount(c),
+			c.Message,
+		)
+	}
+	w.Flush()
+}
+
+// checkpointShow prints the files changed and deleted in a checkpoint
+func checkpointShow(args []string) {
+	flags := flag.NewFlagSet("checkpoint show", flag.ExitOnError)
+	all := flags.Bool("all", false, "list every file in the checkpoint, not only the changed ones")
+	asJSON := flags.Bool("json", false, "print the versions in the checkpoint as JSON")
+	names := parseInterspersed(flags, args)
+
+	if len(names) > 1 {
+		fmt.Println("Usage: ctrls checkpoint show [<id>] [--all] [--json]")
+		os.Exit(1)
+	}
+
+	_, vRepo := openRepositories()
+
+	checkpoint := selectCheckpoint(vRepo, names)
+	versions, err := vRepo.CheckpointVersions(checkpoint, *all)
+	if err != nil {
+		fmt.Println("Error reading checkpoint:", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		printJSON(versions)
+		return
+	}
+
+	fmt.Printf("Checkpoint %s (%s)\n", checkpoint.ID, checkpoint.Hash[:7])
+	fmt.Printf("Taken %s by %s on %s\n", checkpoint.When.Local().Format("2006-01-02 15:04:05"), checkpoint.Author, shortHash(checkpoint.Head))
+	fmt.Printf("\n    %s\n\n", checkpoint.Message)
+
+	changed := map[string]bool{}
+	for _, path := range checkpoint.Changed {
+		changed[path] = true
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, v := range versions {
+		kind := "head"
+		if changed[v.Path] {
+			kind = "changed"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", kind, v.Path, utils.FormatSize(v.Size))
+	}
+	for _, path := range checkpoint.Deleted {
+		fmt.Fprintf(w, "  deleted\t%s\t\n", path)
+	}
+	w.Flush()
+}
+
+// checkpointRestore writes the files of a checkpoint back into the
+// worktree and deletes the files that were deleted in it. The current
+// state is checkpointed first when restoring would change anything.
+func checkpointRestore(args []string) {
+	flags := flag.NewFlagSet("checkpoint restore", flag.ExitOnError)
+	all := flags.Bool("all", false, "restore every file in the checkpoint, not only the changed ones")
+	dryRun := flags.Bool("dry-run", false, "list what would change without touching the worktree")
+	names := parseInterspersed(flags, args)
+
+	if len(names) > 1 {
+		fmt.Println("Usage: ctrls checkpoint restore [<id>] [--all] [--dry-run]")
+		os.Exit(1)
+	}
+
+	repo, vRepo := openRepositories()
+
+	checkpoint := selectCheckpoint(vRepo, names)
+	versions, err := vRepo.CheckpointVersions(checkpoint, *all)
+	if err != nil {
+		fmt.Println("Error reading checkpoint:", err)
+		os.Exit(1)
+	}
+
+	rootPath, err := repo.GetRepoRoot()
+	if err != nil {
+		fmt.Println("You are not in the root of the Git repository.")
+		os.Exit(1)
+	}
+
+	// Only files that differ from the checkpoint are written. Paths come
+	// from the checkpoint, which may have been pulled from a shared remote.
+	var writes []repository.Version
+	dsts := map[string]string{}
+	for _, v := range versions {
+		dst, err := utils.ProjectPath(rootPath, v.Path)
+		if err != nil {
+			fmt.Printf("Refusing to restore %s: %v\n", v.Path, err)
+			os.Exit(1)
+		}
+		dsts[v.Path] = dst
+
+		current, err := utils.ReadFileOrLink(dst)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("Error reading %s: %v\n", v.Path, err)
+			os.Exit(1)
+		}
+		if err == nil {
+			content, err := vRepo.ReadVersion(v)
+			if err != nil {
+				fmt.Printf("Error reading %s from the checkpoint: %v\n", v.Path, err)
+				os.Exit(1)
+			}
+			if bytes.Equal(content, current) {
+				continue
+			}
+		}
+		writes = append(writes, v)
+	}
+	var removals []string
+	for _, path := range checkpoint.Deleted {
+		dst, err := utils.ProjectPath(rootPath, path)
+		if err != nil {
+			fmt.Printf("Refusing to delete %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		dsts[path] = dst
+
+		if _, err := os.Lstat(dst); err == nil {
+			removals = append(removals, path)
+		}
+	}
+
+	if len(writes)+len(removals) == 0 {
+		fmt.Printf("The worktree already matches checkpoint %s\n", checkpoint.ID)
+		return
+	}
+
+	if *dryRun {
+		for _, v := range writes {
+			fmt.Printf("Would restore %s (%s)\n", v.Path, utils.FormatSize(v.Size))
+		}
+		for _, path := range removals {
+			fmt.Printf("Would delete %s\n", path)
+		}
+		return
+	}
+
+	current, err := vRepo.CreateCheckpoint(*repo, "Before restoring checkpoint "+checkpoint.ID)
+	if err != nil {
+		fmt.Println("Error checkpointing the current state:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Checkpointed the current state as %s\n", current.ID)
+
+	for _, v := range writes {
+		err := vRepo.WriteVersion(v, dsts[v.Path])
+		if err != nil {
+			fmt.Printf("Error restoring %s: %v\n", v.Path, err)
+			os.Exit(1)
+		}
+		fmt.Println("Restored", v.Path)
+	}
+	for _, path := range removals {
+		err := os.Remove(dsts[path])
+		if err != nil {
+			fmt.Printf("Error deleting %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Println("Deleted", path)
+	}
+
+	fmt.Printf("Restored checkpoint %s: %d file(s) written, %d deleted\n", checkpoint.ID, len(writes), len(removals))
+}
+
+// selectCheckpoint finds the checkpoint named by names, or the latest one
+func selectCheckpoint(vRepo *repository.Repository, names []string) repository.Checkpoint {
+	checkpoints, err := vRepo.Checkpoints()
+	if err != nil {
+		fmt.Println("Error reading checkpoints:", err)
+		os.Exit(1)
+	}
+
+	if len(names) == 0 {
+		if len(checkpoints) == 0 {
+			fmt.Println("No checkpoints yet. Take one with ctrls checkpoint.")
+			os.Exit(1)
+		}
+		return checkpoints[0]
+	}
+
+	checkpoint, err := repository.FindCheckpoint(checkpoints, names[0])
+	if err != nil {
+		fmt.Println("Error finding checkpoint:", err)
+		os.Exit(1)
+	}
+	return checkpoint
+}
+
+// printCheckpointCreated reports a checkpoint that was just taken
+func printCheckpointCreated(c repository.Checkpoint) {
+	fmt.Printf("Checkpoint %s (%s): %s on %s\n", c.ID, c.Hash[:7], checkpointCount(c), shortHash(c.Head))
+}
+
+// checkpointCount describes how many files a checkpoint changed
+func checkpointCount(c repository.Checkpoint) string {
+	count := fmt.Sprintf("%d changed", len(c.Changed))
+	if len(c.Deleted) > 0 {
+		count += fmt.Sprintf(", %d deleted", len(c.Deleted))
+	}
+	return count
+}
+
+// shortHash abbreviates a commit hash for display
+func shortHash(hash string) string {
+	if hash == "" {
+		return "-"
+	}
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
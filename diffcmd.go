@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/filediff"
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+	"golang.org/x/term"
+)
+
+// runDiff shows what changed in a file between saved versions or since the last save
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	color := flags.String("color", "auto", "colorize output: auto, always or never")
+	stat := flags.Bool("stat", false, "show a diffstat instead of the patch")
+	word := flags.Bool("word", false, "show changes inline word by word")
+	positional := parseInterspersed(flags, args)
+
+	if len(positional) < 1 || len(positional) > 3 || (*stat && *word) {
+		fmt.Println("Usage: ctrls diff <file> [<from-version> [<to-version>]] [--stat | --word] [--color auto|always|never]")
+		os.Exit(1)
+	}
+	file := filepath.ToSlash(filepath.Clean(positional[0]))
+
+	useColor, err := colorEnabled(*color)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	repo, vRepo := openRepositories()
+
+	versions, err := vRepo.FileVersions(file)
+	if err != nil {
+		fmt.Println("Error reading versions:", err)
+		os.Exit(1)
+	}
+
+	// With no versions given, compare the latest save with the working copy
+	fromVersion := versions[0]
+	if len(positional) > 1 {
+		fromVersion, err = repository.SelectVersion(versions, positional[1])
+		if err != nil {
+			fmt.Println("Error finding version:", err)
+			os.Exit(1)
+		}
+	}
+
+	from, err := versionSide(vRepo, fromVersion)
+	if err != nil {
+		fmt.Println("Error reading version:", err)
+		os.Exit(1)
+	}
+
+	var to filediff.Side
+	if len(positional) > 2 {
+		toVersion, err := repository.SelectVersion(versions, positional[2])
+		if err != nil {
+			fmt.Println("Error finding version:", err)
+			os.Exit(1)
+		}
+		to, err = versionSide(vRepo, toVersion)
+		if err != nil {
+			fmt.Println("Error reading version:", err)
+			os.Exit(1)
+		}
+	} else {
+		rootPath, err := repo.GetRepoRoot()
+		if err != nil {
+			fmt.Println("You are not in the root of the Git repository.")
+			os.Exit(1)
+		}
+		content, err := utils.ReadFileOrLink(filepath.Join(rootPath, file))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Println("Error reading working copy:", err)
+			os.Exit(1)
+		}
+		to = filediff.Side{Name: file, Content: content}
+	}
+
+	if bytes.Equal(from.Content, to.Content) {
+		return
+	}
+
+	switch {
+	case *stat:
+		err = filediff.Stat(os.Stdout, from, to, useColor)
+	case *word:
+		err = filediff.Words(os.Stdout, from, to, useColor)
+	default:
+		err = filediff.Unified(os.Stdout, from, to, useColor)
+	}
+	if err != nil {
+		fmt.Println("Error writing diff:", err)
+		os.Exit(1)
+	}
+}
+
+// versionSide loads a saved version for diffing, labelled with its short hash
+func versionSide(vRepo *repository.Repository, v repository.Version) (filediff.Side, error) {
+	content, err := vRepo.ReadVersion(v)
+	if err != nil {
+		return filediff.Side{}, err
+	}
+
+	return filediff.Side{Name: v.Path, Label: v.Hash[:7] + ":", Content: content}, nil
+}
+
+// colorEnabled resolves a --color setting, where auto means only on a terminal
+func colorEnabled(mode string) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		return term.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("NO_COLOR") == "", nil
+	default:
+		return false, fmt.Errorf("invalid --color value %q", mode)
+	}
+}
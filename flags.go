@@ -0,0 +1,27 @@
+package main
+
+import "flag"
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments and returns the positional arguments in order.
+// Everything after a "--" is treated as positional.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, rest = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return append(positional, rest...)
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
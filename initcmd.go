@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/credentials"
+	"github.com/renatonmag/versionctrls-cli/pkg/provider"
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"golang.org/x/term"
+)
+
+// Exit codes of ctrls init, so scripts can tell bad input from failures
+const (
+	exitFailure = 1
+	exitUsage   = 2
+	exitAuth    = 3
+)
+
+const (
+	urlEnv  = "VERSIONCTRLS_URL"
+	pathEnv = "VERSIONCTRLS_PATH"
+)
+
+// initOptions is everything init needs, whether typed in the form or given
+// as flags
+type initOptions struct {
+	URL        string
+	Token      string
+	Path       string
+	Presets    []string
+	CreateRepo bool
+	// Provider is the API used to create the integration repository,
+	// detected from the URL when empty
+	Provider string
+}
+
+// apiTimeout bounds every call init makes to a provider API
+const apiTimeout = 30 * time.Second
+
+// expiryWarning is how close to its expiry an API key gets a warning
+const expiryWarning = 7 * 24 * time.Hour
+
+// errMissingScopes is returned for API keys that cannot push to the
+// integration repository
+var errMissingScopes = errors.New("the API key cannot push to the integration repository")
+
+// runInit adds the integration submodule, asking for the settings in a form
+// when run in a terminal and taking them from flags and the environment
+// otherwise
+func runInit(args []string) {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	url := flags.String("url", os.Getenv(urlEnv), "URL of the integration repository, or $"+urlEnv)
+	tokenEnv := flags.String("token-env", "", "environment variable holding the API key for the integration repository")
+	path := flags.String("path", os.Getenv(pathEnv), "where to add the integration submodule, or $"+pathEnv)
+	presets := flags.String("ignore", "", "comma separated ignore presets to turn on, e.g. node,editors")
+	create := flags.Bool("create", false, "create the integration repository if it does not exist, as <project>-versionctrls when no --url is given")
+	providerName := flags.String("provider", "", "hosting provider API to create the repository with: github, gitlab or gitea")
+	yes := flags.Bool("yes", false, "do not ask anything, even in a terminal")
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		fmt.Println("Usage: ctrls init [--url <url>] [--token-env <name>] [--path <path>] [--ignore <presets>] [--create [--provider <name>]] [--yes]")
+		os.Exit(exitUsage)
+	}
+
+	repo, cfg := openProject()
+	rootPath, _ := repo.GetRepoRoot()
+
+	opts := initOptions{
+		URL:        cfg.Integration.URL,
+		Path:       cfg.Integration.Path,
+		Presets:    cfg.Ignore.Presets,
+		CreateRepo: *create,
+		Provider:   *providerName,
+	}
+	if *url != "" {
+		opts.URL = *url
+	}
+	if *path != "" {
+		opts.Path = *path
+	}
+	if *presets != "" {
+		opts.Presets = strings.Split(*presets, ",")
+	}
+	if opts.URL == "" && opts.CreateRepo && opts.Provider == "github" {
+		// A new repository in the user's own account
+		opts.URL = hostedRepoURL("github.com", defaultRepoName(filepath.Base(rootPath)))
+	}
+	if *tokenEnv != "" {
+		opts.Token = os.Getenv(*tokenEnv)
+		if opts.Token == "" {
+			fmt.Printf("$%s is empty or not set.\n", *tokenEnv)
+			os.Exit(exitUsage)
+		}
+	}
+
+	interactive := !*yes && term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+	var added bool
+	if interactive {
+		// Anything that goes wrong while applying the settings brings the
+		// wizard back with the error instead of starting over
+		wizard := initialModel(opts, filepath.Base(rootPath))
+		for {
+			final, err := tea.NewProgram(wizard).Run()
+			if err != nil {
+				fmt.Printf("Alas, there's been an error: %v", err)
+				os.Exit(exitFailure)
+			}
+
+			wizard = final.(model)
+			if !wizard.initialized {
+				return
+			}
+
+			opts, err = validateInit(wizard.options())
+			if err == nil {
+				added, err = applyInit(repo, rootPath, opts)
+			}
+			if err == nil {
+				break
+			}
+			wizard = wizard.failed(err)
+		}
+	} else {
+		opts, err := validateInit(opts)
+		if err != nil {
+			fmt.Printf("Error in init settings: %v\n", err)
+			os.Exit(exitUsage)
+		}
+
+		if opts.Token != "" {
+			info, err := verifyToken(tokenProvider(opts), credentialHost(opts.URL), opts.Token)
+			if err != nil {
+				code := verifyExitCode(err)
+				if code == exitAuth {
+					fmt.Println(err)
+				} else {
+					fmt.Printf("Error checking API key: %v\n", err)
+				}
+				os.Exit(code)
+			}
+			if line := describeToken(info, time.Now()); line != "" {
+				fmt.Println("API key: " + line)
+			}
+		}
+
+		added, err = applyInit(repo, rootPath, opts)
+		if err != nil {
+			fmt.Printf("Error initializing versionctrls: %v\n", err)
+			os.Exit(exitFailure)
+		}
+	}
+
+	if !added {
+		fmt.Println("Versionctrls is already initialized.")
+	}
+
+	fmt.Printf("\nVersionctrls initialized at: %s\n", rootPath)
+	if added {
+		fmt.Printf("\nCommit the changes to .gitmodules, %s and the %s folder.\n\n", config.ProjectFile, opts.Path)
+	}
+	if interactive {
+		fmt.Printf("\nJust hit ctrl+s and you're good. Your files are safe forever.")
+	}
+}
+
+// validateInit checks and normalizes the settings before anything is changed
+func validateInit(opts initOptions) (initOptions, error) {
+	opts.URL = strings.TrimSpace(opts.URL)
+	opts.Token = strings.TrimSpace(opts.Token)
+	opts.Path = strings.TrimSpace(opts.Path)
+
+	if opts.URL == "" {
+		return opts, fmt.Errorf("enter the URL of your integration repository with --url or $%s", urlEnv)
+	}
+	endpoint, err := transport.NewEndpoint(opts.URL)
+	if err != nil {
+		return opts, fmt.Errorf("%q is not a repository URL: %v", opts.URL, err)
+	}
+	if endpoint.Protocol == "file" {
+		if _, err := os.Stat(endpoint.Path); errors.Is(err, os.ErrNotExist) && !opts.CreateRepo {
+			return opts, fmt.Errorf("%s does not exist, pass --create to create it", endpoint.Path)
+		}
+	}
+
+	if opts.Path == "" {
+		opts.Path = config.DefaultIntegrationPath
+	}
+	for i, preset := range opts.Presets {
+		opts.Presets[i] = strings.TrimSpace(preset)
+	}
+
+	cfg := config.Config{
+		Integration: config.Integration{URL: opts.URL, Path: opts.Path},
+		Ignore:      config.Ignore{Presets: opts.Presets},
+	}
+	if err := cfg.Validate(); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// applyInit creates the integration repository if asked to, saves the API
+// key, adds the integration submodule and records it in the project
+// configuration. It reports false when the submodule was
+// already there.
+func applyInit(repo *repository.Repository, rootPath string, opts initOptions) (bool, error) {
+	// The clone URL of a created repository may point elsewhere, the API key
+	// belongs to the host that was asked for
+	host := credentialHost(opts.URL)
+
+	repo.SetSubmodulePath(opts.Path)
+	exists, err := repo.SubmoduleExists(opts.Path)
+	if err != nil {
+		return false, fmt.Errorf("could not check for submodule: %w", err)
+	}
+	if exists {
+		return false, saveToken(host, opts.Token)
+	}
+
+	if err := validateSubmodulePath(opts.Path); err != nil {
+		return false, err
+	}
+
+	if opts.CreateRepo {
+		url, err := createRepository(opts)
+		if err != nil {
+			return false, fmt.Errorf("could not create integration repository: %w", err)
+		}
+		opts.URL = url
+	}
+
+	if err := saveToken(host, opts.Token); err != nil {
+		return false, err
+	}
+
+	err = repo.AddSubmodule(opts.URL, opts.Path)
+	if err != nil {
+		return false, fmt.Errorf("could not add submodule: %w", err)
+	}
+
+	// New installs keep snapshots out of refs/heads
+	vRepo := repository.New()
+	err = vRepo.PlainOpen(opts.Path)
+	if err == nil {
+		err = vRepo.EnableRefNamespace()
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not configure submodule: %w", err)
+	}
+
+	// Whoever clones the project gets the same integration repository
+	project, err := config.LoadProject(rootPath)
+	if err == nil {
+		project.Integration.URL = opts.URL
+		project.Integration.Path = opts.Path
+		project.Integration.Refs = config.RefsNamespaced
+		project.Ignore.Presets = opts.Presets
+		err = config.SaveProject(rootPath, project)
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not save configuration: %w", err)
+	}
+
+	return true, nil
+}
+
+// tokenProvider returns the provider API the key in opts is meant for, or ""
+// when it cannot tell
+func tokenProvider(opts initOptions) string {
+	if opts.Provider != "" {
+		return opts.Provider
+	}
+	endpoint, err := transport.NewEndpoint(opts.URL)
+	if err != nil {
+		return ""
+	}
+	return provider.Detect(endpoint.Host)
+}
+
+// verifyToken checks token against the API of the provider called name on
+// host. Keys for providers without an API client are taken as they are and
+// get a zero TokenInfo.
+func verifyToken(name, host, token string) (provider.TokenInfo, error) {
+	if name == "" {
+		return provider.TokenInfo{}, nil
+	}
+	client, err := provider.New(name, host, token)
+	if errors.Is(err, provider.ErrUnsupported) {
+		return provider.TokenInfo{}, nil
+	}
+	if err != nil {
+		return provider.TokenInfo{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	info, err := client.VerifyToken(ctx)
+	if err != nil {
+		return info, err
+	}
+	if len(info.Missing) > 0 {
+		return info, fmt.Errorf("%w, it lacks the %s scope", errMissingScopes, strings.Join(info.Missing, ", "))
+	}
+	return info, nil
+}
+
+// verifyExitCode returns the exit code for an API key that verifyToken
+// failed with err: exitAuth when the key itself is the problem, exitFailure
+// when it could not be checked
+func verifyExitCode(err error) int {
+	if errors.Is(err, provider.ErrInvalidToken) || errors.Is(err, errMissingScopes) {
+		return exitAuth
+	}
+	return exitFailure
+}
+
+// describeToken sums up who an API key belongs to and when it expires, or
+// returns "" when nothing is known about it
+func describeToken(info provider.TokenInfo, now time.Time) string {
+	if info.User.Login == "" {
+		return ""
+	}
+
+	line := "belongs to " + info.User.Login
+	switch {
+	case info.ExpiresWithin(expiryWarning, now):
+		line += fmt.Sprintf(", expires on %s, renew it soon", info.Expires.Local().Format("2006-01-02"))
+	case !info.Expires.IsZero():
+		line += fmt.Sprintf(", expires on %s", info.Expires.Local().Format("2006-01-02"))
+	}
+	if info.Scopes == nil {
+		line += ", a fine-grained key whose write access cannot be checked"
+	}
+	return line
+}
+
+// saveToken stores the API key for host, if one was given
+func saveToken(host, token string) error {
+	if token == "" {
+		return nil
+	}
+
+	err := credentialStore().Set(host, credentials.Credential{Secret: token})
+	if err != nil {
+		return fmt.Errorf("could not save API key: %w", err)
+	}
+	return nil
+}
+
+// createRepository creates the integration repository unless it already
+// exists and returns its clone URL. Local bare repositories are created
+// directly, hosted ones through the provider API with the saved API key.
+func createRepository(opts initOptions) (string, error) {
+	endpoint, err := transport.NewEndpoint(opts.URL)
+	if err != nil {
+		return "", err
+	}
+
+	if endpoint.Protocol == "file" {
+		if _, err := os.Stat(endpoint.Path); err == nil {
+			return opts.URL, nil
+		}
+		return opts.URL, repository.InitBareIntegration(endpoint.Path)
+	}
+
+	name := opts.Provider
+	if name == "" {
+		name = provider.Detect(endpoint.Host)
+	}
+	if name == "" {
+		return "", fmt.Errorf("cannot tell which provider hosts %s, pass --provider", endpoint.Host)
+	}
+
+	token := opts.Token
+	if token == "" {
+		cred, err := credentialStore().Get(endpoint.Host)
+		if err != nil {
+			return "", fmt.Errorf("no API key for %s: %w", endpoint.Host, err)
+		}
+		token = cred.Secret
+	}
+
+	client, err := provider.New(name, endpoint.Host, token)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	owner, repoName := splitRepoPath(endpoint.Path)
+	repo, err := client.CreateRepository(ctx, owner, repoName)
+	created := err == nil
+	if errors.Is(err, provider.ErrAlreadyExists) {
+		repo, err = lookupRepository(ctx, client, opts.URL)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if created {
+		fmt.Printf("Created private repository %s/%s\n", repo.Owner, repo.Name)
+	} else {
+		fmt.Printf("Using the existing repository %s/%s\n", repo.Owner, repo.Name)
+	}
+	return repo.CloneURL, nil
+}
+
+// lookupRepository finds the repository at url on a provider, in the
+// account of the API key when url names no owner
+func lookupRepository(ctx context.Context, client provider.Provider, url string) (provider.Repository, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return provider.Repository{}, err
+	}
+
+	owner, name := splitRepoPath(endpoint.Path)
+	if owner == "" {
+		user, err := client.CurrentUser(ctx)
+		if err != nil {
+			return provider.Repository{}, err
+		}
+		owner = user.Login
+	}
+
+	return client.Repository(ctx, owner, name)
+}
+
+// splitRepoPath splits the path of a hosted repository URL into its owner,
+// empty for a bare name, and its name
+func splitRepoPath(path string) (string, string) {
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return "", path
+}
+
+// hostedRepoURL returns the https URL of repo, given as owner/name or just
+// a name, on host
+func hostedRepoURL(host, repo string) string {
+	return "https://" + host + "/" + strings.TrimSuffix(strings.Trim(repo, "/"), ".git") + ".git"
+}
+
+// defaultRepoName is the name suggested for the integration repository of
+// project
+func defaultRepoName(project string) string {
+	return project + "-versionctrls"
+}
+
+// credentialHost returns the host an API key for url is stored under
+func credentialHost(url string) string {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil || endpoint.Host == "" {
+		return defaultHost
+	}
+	return endpoint.Host
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/provider"
+	"github.com/renatonmag/versionctrls-cli/pkg/provider/providertest"
+)
+
+const testToken = "ghp_test"
+
+// newProvider starts a stand-in GitHub API that init talks to instead of
+// the real one
+func newProvider(t *testing.T, orgs ...string) *providertest.Server {
+	t.Helper()
+	srv := providertest.NewServer(testToken, "alice", t.TempDir(), orgs...)
+	t.Cleanup(srv.Close)
+	t.Setenv(provider.APIURLEnv, srv.URL)
+	return srv
+}
+
+func TestCreateRepositoryHosted(t *testing.T) {
+	srv := newProvider(t, "acme")
+
+	for _, tc := range []struct {
+		url, owner, name string
+	}{
+		{hostedRepoURL("github.com", defaultRepoName("project")), "alice", "project-versionctrls"},
+		{hostedRepoURL("github.com", "acme/"+defaultRepoName("project")), "acme", "project-versionctrls"},
+	} {
+		opts := initOptions{URL: tc.url, Token: testToken, Provider: "github", CreateRepo: true}
+		url, err := createRepository(opts)
+		if err != nil {
+			t.Fatalf("createRepository(%s): %v", tc.url, err)
+		}
+
+		var found *provider.Repository
+		for _, repo := range srv.Repositories() {
+			if repo.Owner == tc.owner && repo.Name == tc.name {
+				found = &repo
+			}
+		}
+		if found == nil {
+			t.Fatalf("%s/%s was not created, the server has %v", tc.owner, tc.name, srv.Repositories())
+		}
+		if !found.Private {
+			t.Errorf("%s/%s is not private", tc.owner, tc.name)
+		}
+		if url != found.CloneURL {
+			t.Errorf("createRepository(%s) = %s, want the clone URL %s", tc.url, url, found.CloneURL)
+		}
+	}
+}
+
+func TestCreateRepositoryExisting(t *testing.T) {
+	srv := newProvider(t)
+	existing, err := srv.AddRepository("alice", "project-versionctrls")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider is detected from the URL
+	opts := initOptions{URL: hostedRepoURL("github.com", "project-versionctrls"), Token: testToken, CreateRepo: true}
+	url, err := createRepository(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != existing.CloneURL {
+		t.Errorf("createRepository = %s, want the existing %s", url, existing.CloneURL)
+	}
+	if n := len(srv.Repositories()); n != 1 {
+		t.Errorf("server has %d repositories, want 1", n)
+	}
+}
+
+func TestCreateRepositoryErrors(t *testing.T) {
+	srv := newProvider(t)
+	opts := initOptions{URL: hostedRepoURL("github.com", "project-versionctrls"), Token: "wrong", Provider: "github", CreateRepo: true}
+
+	if _, err := createRepository(opts); err == nil {
+		t.Error("createRepository with a rejected API key succeeded")
+	}
+
+	opts.Token = testToken
+	srv.FailNext(http.StatusInternalServerError, "Server Error")
+	if _, err := createRepository(opts); err == nil {
+		t.Error("createRepository with the API failing succeeded")
+	}
+
+	opts.URL = hostedRepoURL("example.com", "project-versionctrls")
+	opts.Provider = ""
+	if _, err := createRepository(opts); err == nil {
+		t.Error("createRepository on a host with no known provider succeeded")
+	}
+
+	if repos := srv.Repositories(); len(repos) != 0 {
+		t.Errorf("failed attempts created %v", repos)
+	}
+}
+
+func TestCreateRepositoryLocal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "project-versionctrls.git")
+
+	url, err := createRepository(initOptions{URL: path, CreateRepo: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != path {
+		t.Errorf("createRepository = %s, want %s", url, path)
+	}
+	if _, err := os.Stat(filepath.Join(path, "HEAD")); err != nil {
+		t.Errorf("no bare repository at %s: %v", path, err)
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name    string
+		token   string
+		setup   func(*providertest.Server)
+		code    int
+		expires bool
+	}{
+		{name: "valid", token: testToken},
+		{name: "fine-grained", token: testToken, setup: func(s *providertest.Server) { s.SetScopes(nil) }},
+		{name: "expiring", token: testToken, setup: func(s *providertest.Server) { s.SetExpiry(now.Add(48 * time.Hour)) }, expires: true},
+		{name: "missing push scope", token: testToken, setup: func(s *providertest.Server) { s.SetScopes([]string{"read:user"}) }, code: exitAuth},
+		{name: "expired", token: testToken, setup: func(s *providertest.Server) { s.SetExpiry(now.Add(-time.Hour)) }, code: exitAuth},
+		{name: "rejected", token: "wrong", code: exitAuth},
+		{name: "api down", token: testToken, setup: func(s *providertest.Server) { s.FailNext(http.StatusBadGateway, "Bad Gateway") }, code: exitFailure},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newProvider(t)
+			if tc.setup != nil {
+				tc.setup(srv)
+			}
+
+			info, err := verifyToken("github", "github.com", tc.token)
+			if tc.code == 0 {
+				if err != nil {
+					t.Fatalf("verifyToken: %v", err)
+				}
+				if info.User.Login != "alice" {
+					t.Errorf("login = %q, want alice", info.User.Login)
+				}
+				if got := info.ExpiresWithin(expiryWarning, now); got != tc.expires {
+					t.Errorf("expires within the warning = %v, want %v", got, tc.expires)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("verifyToken succeeded")
+			}
+			if code := verifyExitCode(err); code != tc.code {
+				t.Errorf("exit code for %v = %d, want %d", err, code, tc.code)
+			}
+		})
+	}
+}
+
+func TestVerifyTokenUnsupported(t *testing.T) {
+	for _, name := range []string{"", "gitlab", "gitea"} {
+		info, err := verifyToken(name, "example.com", testToken)
+		if err != nil || info.User.Login != "" {
+			t.Errorf("verifyToken(%q) = %+v, %v, want the key taken as it is", name, info, err)
+		}
+	}
+}
@@ -0,0 +1,696 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/ignore"
+	"github.com/renatonmag/versionctrls-cli/pkg/provider"
+)
+
+var (
+	focusedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	blurredStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	cursorStyle  = focusedStyle.Copy()
+	noStyle      = lipgloss.NewStyle()
+)
+
+type wizardStep int
+
+const (
+	stepProvider wizardStep = iota
+	stepCredentials
+	stepRepo
+	stepPath
+	stepIgnore
+	stepSummary
+)
+
+var stepTitles = []string{
+	stepProvider:    "Hosting provider",
+	stepCredentials: "Credentials",
+	stepRepo:        "Integration repository",
+	stepPath:        "Submodule path",
+	stepIgnore:      "Ignore presets",
+	stepSummary:     "Summary",
+}
+
+type providerKind int
+
+const (
+	providerGitHub providerKind = iota
+	providerGitLab
+	providerGitea
+	providerURL
+	providerLocal
+)
+
+// providerChoice is one of the places the integration repository can live
+type providerChoice struct {
+	kind        providerKind
+	name        string
+	api         string
+	host        string
+	description string
+}
+
+var providerChoices = []providerChoice{
+	{providerGitHub, "GitHub", "github", "github.com", "repositories on github.com"},
+	{providerGitLab, "GitLab", "gitlab", "gitlab.com", "gitlab.com or a self-hosted instance"},
+	{providerGitea, "Gitea", "gitea", "gitea.com", "gitea.com or a self-hosted instance"},
+	{providerURL, "Plain git URL", "", "", "any remote reachable over https or ssh"},
+	{providerLocal, "Local bare repository", "", "", "a bare repository on this machine or a mounted drive"},
+}
+
+// hosted reports whether repositories are named owner/name on a host
+func (p providerChoice) hosted() bool {
+	return p.kind == providerGitHub || p.kind == providerGitLab || p.kind == providerGitea
+}
+
+const (
+	fieldHost = iota
+	fieldToken
+	fieldRepo
+	fieldPath
+	numFields
+)
+
+// credentialsCheckedMsg reports the outcome of checking the credentials
+type credentialsCheckedMsg struct {
+	info provider.TokenInfo
+	err  error
+}
+
+// repoCheckedMsg reports the clone URL of the integration repository, or that
+// it has to be created
+type repoCheckedMsg struct {
+	url    string
+	create bool
+	err    error
+}
+
+// model is the state of the init wizard
+type model struct {
+	step     wizardStep
+	cursor   int
+	provider providerChoice
+	inputs   [numFields]textinput.Model
+	focus    int
+	presets  map[string]bool
+	project  string
+
+	// tokenInfo is what the provider told about the API key
+	tokenInfo provider.TokenInfo
+
+	// cloneURL and createRepo are what looking up the repository found
+	cloneURL   string
+	createRepo bool
+
+	busy        bool
+	err         error
+	initialized bool
+}
+
+func initialModel(opts initOptions, project string) model {
+	m := model{
+		provider: providerChoices[0],
+		presets:  map[string]bool{},
+		project:  project,
+	}
+
+	for i := range m.inputs {
+		t := textinput.New()
+		t.Cursor.Style = cursorStyle
+		// Tokens and URLs are routinely longer than any fixed limit
+		t.CharLimit = 0
+		t.Width = 60
+		m.inputs[i] = t
+	}
+
+	m.inputs[fieldHost].Placeholder = "Host"
+	m.inputs[fieldToken].Placeholder = "API key"
+	m.inputs[fieldToken].EchoMode = textinput.EchoPassword
+	m.inputs[fieldToken].EchoCharacter = '•'
+	m.inputs[fieldToken].SetValue(opts.Token)
+	m.inputs[fieldPath].SetValue(opts.Path)
+
+	// Settings from the project configuration or flags preselect the matching
+	// provider and fill in the repository
+	if opts.URL != "" {
+		m.provider, m.cursor = guessProvider(opts.URL)
+		m.inputs[fieldRepo].SetValue(opts.URL)
+	} else {
+		m.inputs[fieldRepo].SetValue(defaultRepoName(project))
+	}
+	m.inputs[fieldHost].SetValue(m.provider.host)
+
+	for _, name := range opts.Presets {
+		m.presets[name] = true
+	}
+
+	ready, _ := m.enterStep()
+	return ready.(model)
+}
+
+// guessProvider picks the provider choice an existing URL belongs to
+func guessProvider(url string) (providerChoice, int) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err == nil && endpoint.Protocol == "file" {
+		return providerChoices[providerLocal], int(providerLocal)
+	}
+	for i, choice := range providerChoices {
+		if choice.host != "" && err == nil && endpoint.Host == choice.host {
+			return choice, i
+		}
+	}
+	return providerChoices[providerURL], int(providerURL)
+}
+
+func (m model) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case credentialsCheckedMsg:
+		m.busy = false
+		m.err = msg.err
+		if msg.err != nil {
+			return m, nil
+		}
+		m.tokenInfo = msg.info
+		return m.next()
+
+	case repoCheckedMsg:
+		m.busy = false
+		m.err = msg.err
+		if msg.err != nil {
+			return m, nil
+		}
+		m.cloneURL, m.createRepo = msg.url, msg.create
+		return m.next()
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		if m.busy {
+			return m, nil
+		}
+
+		switch msg.String() {
+		case "esc":
+			if m.step == stepProvider {
+				return m, tea.Quit
+			}
+			return m.back()
+
+		case "enter":
+			return m.confirm()
+
+		case "up", "shift+tab", "down", "tab":
+			forward := msg.String() == "down" || msg.String() == "tab"
+			if fields := m.fields(); len(fields) > 0 {
+				return m.moveFocus(forward)
+			}
+			m.moveCursor(forward)
+			return m, nil
+
+		case " ":
+			if m.step == stepIgnore {
+				name := ignore.Presets[m.cursor].Name
+				m.presets[name] = !m.presets[name]
+				return m, nil
+			}
+		}
+	}
+
+	if fields := m.fields(); len(fields) > 0 {
+		var cmd tea.Cmd
+		i := fields[m.focus]
+		m.inputs[i], cmd = m.inputs[i].Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// fields returns the text inputs shown on the current step
+func (m model) fields() []int {
+	switch m.step {
+	case stepCredentials:
+		if m.provider.kind == providerGitLab || m.provider.kind == providerGitea {
+			return []int{fieldHost, fieldToken}
+		}
+		return []int{fieldToken}
+	case stepRepo:
+		return []int{fieldRepo}
+	case stepPath:
+		return []int{fieldPath}
+	}
+	return nil
+}
+
+// confirm validates the current step and moves on when it is fine
+func (m model) confirm() (tea.Model, tea.Cmd) {
+	m.err = nil
+
+	switch m.step {
+	case stepProvider:
+		choice := providerChoices[m.cursor]
+		if choice.kind != m.provider.kind {
+			m.provider = choice
+			m.inputs[fieldHost].SetValue(choice.host)
+			m.inputs[fieldToken].SetValue("")
+			m.inputs[fieldRepo].SetValue("")
+			if choice.hosted() {
+				m.inputs[fieldRepo].SetValue(defaultRepoName(m.project))
+			}
+		}
+
+	case stepCredentials:
+		m.tokenInfo = provider.TokenInfo{}
+		m.busy = true
+		return m, m.checkCredentials()
+
+	case stepRepo:
+		m.cloneURL, m.createRepo = "", false
+		m.err = m.validateRepo()
+		if m.err == nil && m.provider.hosted() {
+			m.busy = true
+			return m, m.checkRepo()
+		}
+
+	case stepPath:
+		m.err = validateSubmodulePath(strings.TrimSpace(m.inputs[fieldPath].Value()))
+
+	case stepSummary:
+		m.initialized = true
+		return m, tea.Quit
+	}
+
+	if m.err != nil {
+		return m, nil
+	}
+	return m.next()
+}
+
+// checkCredentials makes sure an API key was entered where one is required
+// and, for providers with an API client, that it can push to the integration
+// repository
+func (m model) checkCredentials() tea.Cmd {
+	host := strings.TrimSpace(m.inputs[fieldHost].Value())
+	token := strings.TrimSpace(m.inputs[fieldToken].Value())
+	choice := m.provider
+
+	return func() tea.Msg {
+		switch {
+		case choice.hosted() && host == "":
+			return credentialsCheckedMsg{err: errors.New("enter the host of your " + choice.name + " instance")}
+		case choice.hosted() && token == "":
+			return credentialsCheckedMsg{err: errors.New("enter an API key with access to the integration repository")}
+		case strings.ContainsAny(token, " \t"):
+			return credentialsCheckedMsg{err: errors.New("API keys cannot contain spaces")}
+		case token == "":
+			return credentialsCheckedMsg{}
+		}
+
+		info, err := verifyToken(choice.api, host, token)
+		return credentialsCheckedMsg{info: info, err: err}
+	}
+}
+
+// validateRepo checks the repository entered for the chosen provider
+func (m model) validateRepo() error {
+	value := strings.TrimSpace(m.inputs[fieldRepo].Value())
+	if value == "" {
+		return errors.New("enter the integration repository")
+	}
+
+	switch m.provider.kind {
+	case providerLocal:
+		path, err := filepath.Abs(value)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if _, err := os.Stat(filepath.Dir(path)); err != nil {
+				return fmt.Errorf("%s does not exist", filepath.Dir(path))
+			}
+			return nil
+		}
+		if !isBareRepository(path) {
+			return fmt.Errorf("%s is not a bare git repository", path)
+		}
+		return nil
+
+	default:
+		if m.provider.hosted() && !strings.Contains(value, "://") && !strings.Contains(value, "@") {
+			if strings.ContainsAny(value, " \t") || strings.HasPrefix(value, "/") {
+				return errors.New("enter the repository as owner/name, or just a name for your own account")
+			}
+			return nil
+		}
+		if _, err := transport.NewEndpoint(value); err != nil {
+			return fmt.Errorf("not a repository URL: %v", err)
+		}
+		return nil
+	}
+}
+
+// checkRepo looks the repository up through the provider API, to use the
+// clone URL it reports or to offer creating it. Providers without an API
+// client get the URL as typed.
+func (m model) checkRepo() tea.Cmd {
+	url := m.repoURL()
+	api := m.provider.api
+	host := strings.TrimSpace(m.inputs[fieldHost].Value())
+	token := strings.TrimSpace(m.inputs[fieldToken].Value())
+
+	return func() tea.Msg {
+		client, err := provider.New(api, host, token)
+		if errors.Is(err, provider.ErrUnsupported) {
+			return repoCheckedMsg{url: url}
+		}
+		if err != nil {
+			return repoCheckedMsg{err: err}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+		defer cancel()
+
+		repo, err := lookupRepository(ctx, client, url)
+		if errors.Is(err, provider.ErrNotFound) {
+			return repoCheckedMsg{url: url, create: true}
+		}
+		if err != nil {
+			return repoCheckedMsg{err: err}
+		}
+		return repoCheckedMsg{url: repo.CloneURL}
+	}
+}
+
+// repoURL returns the URL of the integration repository from what was typed
+func (m model) repoURL() string {
+	value := strings.TrimSpace(m.inputs[fieldRepo].Value())
+
+	switch {
+	case m.provider.kind == providerLocal:
+		if path, err := filepath.Abs(value); err == nil {
+			return path
+		}
+	case m.provider.hosted() && !strings.Contains(value, "://") && !strings.Contains(value, "@"):
+		return hostedRepoURL(strings.TrimSpace(m.inputs[fieldHost].Value()), value)
+	}
+	return value
+}
+
+// options returns the settings chosen in the wizard
+func (m model) options() initOptions {
+	opts := initOptions{
+		URL:      m.repoURL(),
+		Token:    strings.TrimSpace(m.inputs[fieldToken].Value()),
+		Path:     strings.TrimSpace(m.inputs[fieldPath].Value()),
+		Provider: m.provider.api,
+	}
+	if m.provider.hosted() && m.cloneURL != "" {
+		opts.URL = m.cloneURL
+		opts.CreateRepo = m.createRepo
+	}
+	if m.provider.kind == providerLocal {
+		opts.Token = ""
+		_, err := os.Stat(opts.URL)
+		opts.CreateRepo = errors.Is(err, os.ErrNotExist)
+	}
+	for _, preset := range ignore.Presets {
+		if m.presets[preset.Name] {
+			opts.Presets = append(opts.Presets, preset.Name)
+		}
+	}
+	return opts
+}
+
+// failed reopens the summary with an error that happened while applying it
+func (m model) failed(err error) model {
+	m.step = stepSummary
+	m.initialized = false
+	m.err = err
+	return m
+}
+
+func (m model) next() (tea.Model, tea.Cmd) {
+	m.step++
+	if m.step == stepCredentials && m.provider.kind == providerLocal {
+		m.step++
+	}
+	return m.enterStep()
+}
+
+func (m model) back() (tea.Model, tea.Cmd) {
+	m.err = nil
+	m.step--
+	if m.step == stepCredentials && m.provider.kind == providerLocal {
+		m.step--
+	}
+	return m.enterStep()
+}
+
+// enterStep resets the focus and placeholders for the current step
+func (m model) enterStep() (tea.Model, tea.Cmd) {
+	m.cursor = 0
+	if m.step == stepProvider {
+		m.cursor = int(m.provider.kind)
+	}
+
+	switch m.provider.kind {
+	case providerLocal:
+		m.inputs[fieldRepo].Placeholder = "/path/to/integration.git"
+	case providerURL:
+		m.inputs[fieldRepo].Placeholder = "https://example.com/you/integration.git"
+		m.inputs[fieldToken].Placeholder = "API key (optional)"
+	default:
+		m.inputs[fieldRepo].Placeholder = "owner/name"
+		m.inputs[fieldToken].Placeholder = m.provider.name + " API key"
+	}
+
+	m.focus = 0
+	return m.focusField()
+}
+
+func (m model) moveFocus(forward bool) (tea.Model, tea.Cmd) {
+	fields := m.fields()
+	if forward {
+		m.focus = (m.focus + 1) % len(fields)
+	} else {
+		m.focus = (m.focus + len(fields) - 1) % len(fields)
+	}
+	return m.focusField()
+}
+
+func (m model) focusField() (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	fields := m.fields()
+	for i := range m.inputs {
+		if len(fields) > 0 && i == fields[m.focus] {
+			cmd = m.inputs[i].Focus()
+			m.inputs[i].PromptStyle = focusedStyle
+			m.inputs[i].TextStyle = focusedStyle
+			continue
+		}
+		m.inputs[i].Blur()
+		m.inputs[i].PromptStyle = noStyle
+		m.inputs[i].TextStyle = noStyle
+	}
+	return m, cmd
+}
+
+func (m *model) moveCursor(forward bool) {
+	n := len(providerChoices)
+	if m.step == stepIgnore {
+		n = len(ignore.Presets)
+	}
+	if n == 0 {
+		return
+	}
+	if forward {
+		m.cursor = (m.cursor + 1) % n
+	} else {
+		m.cursor = (m.cursor + n - 1) % n
+	}
+}
+
+func (m model) View() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Initializing Versionctrls - %s\n\n", focusedStyle.Render(stepTitles[m.step]))
+
+	switch m.step {
+	case stepProvider:
+		b.WriteString("Where should the integration repository live?\n\n")
+		for i, choice := range providerChoices {
+			line := fmt.Sprintf("%-22s %s", choice.name, blurredStyle.Render(choice.description))
+			b.WriteString(listItem(i == m.cursor, line))
+		}
+
+	case stepCredentials:
+		if m.provider.kind == providerURL {
+			b.WriteString("API key for the repository host, if it asks for one:\n\n")
+		} else {
+			fmt.Fprintf(&b, "API key for %s, used to push your versions:\n\n", m.provider.name)
+		}
+		b.WriteString(m.fieldsView())
+
+	case stepRepo:
+		switch m.provider.kind {
+		case providerLocal:
+			b.WriteString("Path of the bare repository, it is created if it does not exist:\n\n")
+		case providerURL:
+			b.WriteString("URL of the integration repository:\n\n")
+		default:
+			fmt.Fprintf(&b, "Repository on %s, created if it does not exist:\n\n", strings.TrimSpace(m.inputs[fieldHost].Value()))
+		}
+		b.WriteString(m.fieldsView())
+
+	case stepPath:
+		b.WriteString("Where to add the integration submodule in this repository:\n\n")
+		b.WriteString(m.fieldsView())
+
+	case stepIgnore:
+		b.WriteString("Files that should never be versioned:\n\n")
+		for i, preset := range ignore.Presets {
+			box := "[ ]"
+			if m.presets[preset.Name] {
+				box = "[x]"
+			}
+			line := fmt.Sprintf("%s %-8s %s", box, preset.Name, blurredStyle.Render(preset.Description))
+			b.WriteString(listItem(i == m.cursor, line))
+		}
+
+	case stepSummary:
+		opts := m.options()
+		fmt.Fprintf(&b, "  Provider     %s\n", m.provider.name)
+		fmt.Fprintf(&b, "  Repository   %s", opts.URL)
+		if opts.CreateRepo && m.provider.hosted() {
+			b.WriteString(blurredStyle.Render(" (will be created as a private repository)"))
+		} else if opts.CreateRepo {
+			b.WriteString(blurredStyle.Render(" (will be created)"))
+		}
+		b.WriteString("\n")
+		if opts.Token != "" {
+			fmt.Fprintf(&b, "  API key      %s", maskSecret(opts.Token))
+			if line := describeToken(m.tokenInfo, time.Now()); line != "" {
+				style := blurredStyle
+				if m.tokenInfo.ExpiresWithin(expiryWarning, time.Now()) {
+					style = errorStyle
+				}
+				b.WriteString(style.Render(" (" + line + ")"))
+			}
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "  Path         %s\n", opts.Path)
+		presets := strings.Join(opts.Presets, ", ")
+		if presets == "" {
+			presets = "none"
+		}
+		fmt.Fprintf(&b, "  Ignore       %s\n", presets)
+	}
+
+	if m.busy {
+		b.WriteString("\n" + blurredStyle.Render("Checking..."))
+	}
+	if m.err != nil {
+		b.WriteString("\n" + errorStyle.Render("✗ "+m.err.Error()) + "\n")
+	}
+
+	b.WriteString("\n" + blurredStyle.Render(m.help()) + "\n")
+	return b.String()
+}
+
+func (m model) fieldsView() string {
+	var b strings.Builder
+	for _, i := range m.fields() {
+		b.WriteString(m.inputs[i].View() + "\n")
+	}
+	return b.String()
+}
+
+func (m model) help() string {
+	switch m.step {
+	case stepProvider:
+		return "↑/↓ choose • enter continue • esc quit"
+	case stepIgnore:
+		return "↑/↓ move • space toggle • enter continue • esc back"
+	case stepSummary:
+		return "enter initialize • esc back • ctrl+c quit"
+	}
+	if len(m.fields()) > 1 {
+		return "tab next field • enter continue • esc back"
+	}
+	return "enter continue • esc back"
+}
+
+func listItem(selected bool, line string) string {
+	if selected {
+		return focusedStyle.Render("> ") + line + "\n"
+	}
+	return "  " + line + "\n"
+}
+
+// validateSubmodulePath checks that the integration submodule can be added
+// at path
+func validateSubmodulePath(path string) error {
+	if path == "" {
+		return errors.New("enter a path for the integration submodule")
+	}
+
+	cfg := config.Config{Integration: config.Integration{Path: path}}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s already exists and is not a directory", path)
+	}
+
+	// An existing checkout is fine, init leaves it alone
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == ".git" {
+			return nil
+		}
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%s already exists and is not empty", path)
+	}
+	return nil
+}
+
+// isBareRepository reports whether path looks like a bare git repository
+func isBareRepository(path string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(path, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
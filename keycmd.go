@@ -0,0 +1,370 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"filippo.io/age"
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/encryption"
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"golang.org/x/term"
+)
+
+const (
+	// passphraseEnv holds the passphrase of the passphrase encryption
+	// method, for when there is no terminal to ask on
+	passphraseEnv = "VERSIONCTRLS_ENCRYPTION_PASSPHRASE"
+	// newPassphraseEnv holds the passphrase to change to on rotation
+	newPassphraseEnv = "VERSIONCTRLS_NEW_ENCRYPTION_PASSPHRASE"
+)
+
+// runKey manages the keys snapshot contents are encrypted with
+func runKey(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: ctrls key generate|add|remove|list|passphrase|rotate")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "generate":
+		keyGenerate(args[1:])
+	case "add":
+		keyRecipients(args[1:], true)
+	case "remove":
+		keyRecipients(args[1:], false)
+	case "list":
+		keyList()
+	case "passphrase":
+		keyPassphrase()
+	case "rotate":
+		keyRotate(args[1:])
+	default:
+		fmt.Printf("Unknown key command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// keyGenerate creates this user's key pair
+func keyGenerate(args []string) {
+	flags := flag.NewFlagSet("key generate", flag.ExitOnError)
+	force := flags.Bool("force", false, "create a new key even if there is one, keeping the old one to read older versions")
+	flags.Parse(args)
+
+	_, cfg := openProject()
+	path, err := encryption.IdentityPath(cfg.Encryption)
+	if err != nil {
+		fmt.Println("Error finding the key file:", err)
+		os.Exit(1)
+	}
+
+	existing, err := encryption.ReadIdentities(path)
+	if err != nil {
+		fmt.Println("Error reading keys:", err)
+		os.Exit(1)
+	}
+	if len(existing) > 0 && !*force {
+		fmt.Printf("You already have a key in %s, its public key is\n\n  %s\n\nUse ctrls key rotate to replace it.\n", path, existing[0].Recipient())
+		os.Exit(1)
+	}
+
+	identity, err := encryption.GenerateIdentity(path)
+	if err != nil {
+		fmt.Println("Error creating key:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Created a key in %s, keep it safe: without it your encrypted versions cannot be read.\n", path)
+	fmt.Printf("\nPublic key: %s\n", identity.Recipient())
+	fmt.Printf("\nThe public key can be shared. Add it to the project with\n\n  ctrls key add %s\n", identity.Recipient())
+}
+
+// keyRecipients adds or removes public keys of the project
+func keyRecipients(args []string, add bool) {
+	verb := "remove"
+	if add {
+		verb = "add"
+	}
+	if len(args) == 0 {
+		fmt.Printf("Usage: ctrls key %s <public key>...\n", verb)
+		os.Exit(1)
+	}
+
+	repo, _ := openProject()
+	rootPath, err := repo.GetRepoRoot()
+	if err != nil {
+		fmt.Println("You are not in the root of the Git repository.")
+		os.Exit(1)
+	}
+
+	project, err := config.LoadProject(rootPath)
+	if err != nil {
+		fmt.Println("Error reading configuration:", err)
+		os.Exit(1)
+	}
+	if project.Encryption.Method == config.EncryptPassphrase {
+		fmt.Println("This project is encrypted with a passphrase, which has no recipients.")
+		os.Exit(1)
+	}
+
+	recipients := project.Encryption.Recipients
+	for _, arg := range args {
+		if _, err := age.ParseX25519Recipient(arg); err != nil {
+			fmt.Printf("%s is not a public key: %v\n", arg, err)
+			os.Exit(1)
+		}
+
+		index := slices.Index(recipients, arg)
+		switch {
+		case add && index < 0:
+			recipients = append(recipients, arg)
+		case !add && index >= 0:
+			recipients = slices.Delete(recipients, index, index+1)
+		case !add:
+			fmt.Printf("%s is not a recipient of this project\n", arg)
+			os.Exit(1)
+		}
+	}
+
+	project.Encryption.Recipients = recipients
+	project.Encryption.Method = config.EncryptAge
+	if len(recipients) == 0 {
+		project.Encryption.Method = ""
+	}
+	if err := config.SaveProject(rootPath, project); err != nil {
+		fmt.Println("Error saving configuration:", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case add:
+		fmt.Printf("Versions saved from now on are encrypted for %d %s. Run ctrls key rotate --keep-key to re-encrypt the ones saved so far for them too.\n", len(recipients), plural(len(recipients), "recipient"))
+	case len(recipients) == 0:
+		fmt.Println("Removed the last recipient, versions saved from now on are not encrypted.")
+	default:
+		fmt.Println("Versions saved from now on are not readable with the removed keys. Run ctrls key rotate --keep-key to re-encrypt the ones saved so far without them.")
+	}
+}
+
+// keyList shows how the project is encrypted and for whom
+func keyList() {
+	_, cfg := openProject()
+
+	switch cfg.Encryption.Method {
+	case "":
+		fmt.Println("Versions are not encrypted. Set it up with ctrls key generate or ctrls key passphrase.")
+		return
+	case config.EncryptPassphrase:
+		fmt.Println("Versions are encrypted with a passphrase.")
+		return
+	}
+
+	path, err := encryption.IdentityPath(cfg.Encryption)
+	if err != nil {
+		fmt.Println("Error finding the key file:", err)
+		os.Exit(1)
+	}
+	identities, err := encryption.ReadIdentities(path)
+	if err != nil {
+		fmt.Println("Error reading keys:", err)
+		os.Exit(1)
+	}
+	mine := map[string]bool{}
+	for _, identity := range identities {
+		mine[identity.Recipient().String()] = true
+	}
+
+	fmt.Println("Versions are encrypted for:")
+	for _, recipient := range cfg.Encryption.Recipients {
+		if mine[recipient] {
+			fmt.Printf("  %s (you)\n", recipient)
+		} else {
+			fmt.Printf("  %s\n", recipient)
+		}
+	}
+}
+
+// keyPassphrase switches the project to a new passphrase
+func keyPassphrase() {
+	repo, vRepo := openRepositories()
+	rootPath, err := repo.GetRepoRoot()
+	if err != nil {
+		fmt.Println("You are not in the root of the Git repository.")
+		os.Exit(1)
+	}
+	switchPassphrase(vRepo, rootPath)
+}
+
+// switchPassphrase sets a new passphrase with a new salt for the project at
+// rootPath and re-encrypts the versions saved so far with it
+func switchPassphrase(vRepo *repository.Repository, rootPath string) {
+	project, err := config.LoadProject(rootPath)
+	if err != nil {
+		fmt.Println("Error reading configuration:", err)
+		os.Exit(1)
+	}
+	project.Encryption = newPassphraseEncryption()
+
+	reencrypt(vRepo, project.Encryption, func() (string, error) {
+		return readPassphrase(newPassphraseEnv, "New encryption passphrase: ", true)
+	})
+
+	if err := config.SaveProject(rootPath, project); err != nil {
+		fmt.Println("Error saving configuration:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Versions are now encrypted with the new passphrase.")
+}
+
+// keyRotate replaces this user's key, or the passphrase, and re-encrypts
+// every version saved so far
+func keyRotate(args []string) {
+	flags := flag.NewFlagSet("key rotate", flag.ExitOnError)
+	keepKey := flags.Bool("keep-key", false, "only re-encrypt, for the recipients as they are now")
+	flags.Parse(args)
+
+	repo, vRepo := openRepositories()
+	rootPath, err := repo.GetRepoRoot()
+	if err != nil {
+		fmt.Println("You are not in the root of the Git repository.")
+		os.Exit(1)
+	}
+	cfg, err := config.Load(rootPath)
+	if err != nil {
+		fmt.Println("Error reading configuration:", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case cfg.Encryption.Method == "":
+		fmt.Println("Versions are not encrypted, there is no key to rotate.")
+		os.Exit(1)
+	case *keepKey:
+		reencrypt(vRepo, cfg.Encryption, encryptionPassphrase)
+		return
+	case cfg.Encryption.Method == config.EncryptPassphrase:
+		switchPassphrase(vRepo, rootPath)
+		return
+	}
+
+	project, err := config.LoadProject(rootPath)
+	if err != nil {
+		fmt.Println("Error reading configuration:", err)
+		os.Exit(1)
+	}
+
+	path, err := encryption.IdentityPath(cfg.Encryption)
+	if err != nil {
+		fmt.Println("Error finding the key file:", err)
+		os.Exit(1)
+	}
+	old, err := encryption.ReadIdentities(path)
+	if err != nil {
+		fmt.Println("Error reading keys:", err)
+		os.Exit(1)
+	}
+	// Older keys stay in the file, so versions not re-encrypted yet can
+	// still be read
+	identity, err := encryption.GenerateIdentity(path)
+	if err != nil {
+		fmt.Println("Error creating key:", err)
+		os.Exit(1)
+	}
+
+	var recipients []string
+	for _, recipient := range project.Encryption.Recipients {
+		if !slices.ContainsFunc(old, func(i *age.X25519Identity) bool { return i.Recipient().String() == recipient }) {
+			recipients = append(recipients, recipient)
+		}
+	}
+	project.Encryption.Recipients = append(recipients, identity.Recipient().String())
+	cfg.Encryption.Recipients = project.Encryption.Recipients
+
+	reencrypt(vRepo, cfg.Encryption, encryptionPassphrase)
+
+	if err := config.SaveProject(rootPath, project); err != nil {
+		fmt.Println("Error saving configuration:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Your new public key is %s\n", identity.Recipient())
+}
+
+// reencrypt rewrites the history of the integration repository for the
+// encryption settings enc, exiting on failure
+func reencrypt(vRepo *repository.Repository, enc config.Encryption, passphrase func() (string, error)) {
+	old := vRepo.Keyring()
+	keys, err := encryption.Load(enc, passphrase)
+	if err != nil {
+		fmt.Println("Error reading encryption keys:", err)
+		os.Exit(1)
+	}
+	vRepo.SetEncryption(keys)
+
+	changed, err := vRepo.ReencryptHistory(old)
+	if err != nil {
+		fmt.Println("Error re-encrypting versions:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Re-encrypted the versions of %d %s.\n", len(changed), plural(len(changed), "file"))
+	if len(changed) > 0 {
+		fmt.Println("History was rewritten: run ctrls push --force to replace the versions on the remote. Other clones of the integration repository have to be cloned again.")
+	}
+}
+
+// newPassphraseEncryption returns settings for the passphrase method with a
+// fresh salt
+func newPassphraseEncryption() config.Encryption {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		fmt.Println("Error creating salt:", err)
+		os.Exit(1)
+	}
+	return config.Encryption{Method: config.EncryptPassphrase, Salt: hex.EncodeToString(salt)}
+}
+
+// encryptionPassphrase reads the passphrase versions are encrypted with
+func encryptionPassphrase() (string, error) {
+	return readPassphrase(passphraseEnv, "Encryption passphrase: ", false)
+}
+
+// readPassphrase reads a passphrase from env or, on a terminal, without
+// echoing it, twice when confirm is set
+func readPassphrase(env, prompt string, confirm bool) (string, error) {
+	if passphrase := os.Getenv(env); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to ask for the passphrase on, set %s", env)
+	}
+
+	fmt.Print(prompt)
+	raw, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if len(raw) == 0 {
+		return "", errors.New("empty passphrase")
+	}
+
+	if confirm {
+		fmt.Print("Repeat it: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(raw) {
+			return "", errors.New("the passphrases do not match")
+		}
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// runLog lists the saved versions of a file
+func runLog(args []string) {
+	flags := flag.NewFlagSet("log", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print versions as JSON")
+	files := parseInterspersed(flags, args)
+
+	if len(files) != 1 {
+		fmt.Println("Usage: ctrls log <file> [--json]")
+		os.Exit(1)
+	}
+
+	_, vRepo := openRepositories()
+
+	versions, err := vRepo.FileVersions(files[0])
+	if err != nil {
+		fmt.Println("Error reading versions:", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		printJSON(versions)
+		return
+	}
+
+	printVersions(versions)
+}
+
+// printVersions writes versions as an aligned table
+func printVersions(versions []repository.Version) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, v := range versions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			v.Hash[:7],
+			versionName(v),
+			v.When.Local().Format("2006-01-02 15:04:05"),
+			v.Author,
+			utils.FormatSize(v.Size),
+			versionMessage(v),
+		)
+	}
+	w.Flush()
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Println("Error encoding JSON:", err)
+		os.Exit(1)
+	}
+}
+
+// versionMessage returns the message of v for display, noting renames
+func versionMessage(v repository.Version) string {
+	if v.RenamedFrom == "" {
+		return v.Message
+	}
+	return v.Message + " (renamed from " + v.RenamedFrom + ")"
+}
+
+// versionName returns the version number of v for display
+func versionName(v repository.Version) string {
+	if v.Label == "" {
+		return "-"
+	}
+	return "v" + v.Label
+}
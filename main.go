@@ -179,214 +179,61 @@
 
 package main
 
-// A simple example demonstrating the use of multiple text input components
-// from the Bubbles component library.
-
 import (
 	"fmt"
 	"log"
 	"os"
-	"strings"
 
-	"github.com/charmbracelet/bubbles/textinput"
-	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 	"github.com/renatonmag/versionctrls-cli/pkg/repository"
 )
 
-var (
-	focusedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-	blurredStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
-	cursorStyle  = focusedStyle.Copy()
-	noStyle      = lipgloss.NewStyle()
-
-	focusedButton = focusedStyle.Copy().Render("[ Initialize ]")
-	blurredButton = fmt.Sprintf("[ %s ]", blurredStyle.Render("Initialize"))
-)
-
-const (
-	stateInit = iota
-	end
-)
-
-const secretFilePath = "apikey.txt"
-
-type model struct {
-	focusIndex  int
-	inputs      []textinput.Model
-	initialized bool
-	state       int
-}
-
-func initialModel() model {
-	m := model{
-		inputs: make([]textinput.Model, 2),
-	}
-
-	var t textinput.Model
-	for i := range m.inputs {
-		t = textinput.New()
-		t.Cursor.Style = cursorStyle
-		t.CharLimit = 32
-
-		switch i {
-		case 0:
-			t.Placeholder = "GitHub API key"
-			t.Focus()
-			t.PromptStyle = focusedStyle
-			t.TextStyle = focusedStyle
-			t.EchoMode = textinput.EchoPassword
-			t.EchoCharacter = '•'
-		case 1:
-			t.Placeholder = "Versionctrls integration repository URL"
-		}
-
-		m.inputs[i] = t
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println("Usage: ctrls <command>")
+		os.Exit(1)
 	}
 
-	return m
-}
-
-func (m model) Init() tea.Cmd {
-	return textinput.Blink
-}
+	cmd := os.Args[1]
 
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "esc", "q":
-			return m, tea.Quit
-
-		// Set focus to next input
-		case "tab", "shift+tab", "enter", "up", "down":
-			s := msg.String()
-
-			// Did the user press enter while the submit button was focused?
-			// If so, exit.
-			if s == "enter" && m.focusIndex == len(m.inputs) {
-				m.initialized = true
-				return m, tea.Quit
-			}
+	if cmd == "cleanbranch" {
+		repo, vRepo := openRepositories()
 
-			// Cycle indexes
-			if s == "up" || s == "shift+tab" {
-				m.focusIndex--
-			} else {
-				m.focusIndex++
-			}
+		// Everything recorded in this run is one changeset
+		vRepo.BeginChangeset()
 
-			if m.focusIndex > len(m.inputs) {
-				m.focusIndex = 0
-			} else if m.focusIndex < 0 {
-				m.focusIndex = len(m.inputs)
+		// Deleted and renamed files leave nothing behind in the submodule to
+		// commit, so they are recorded from the changes in the project
+		changes, err := repo.FileChanges()
+		if err != nil {
+			log.Fatalf("Error getting changed files: %v", err)
+		}
+		for _, change := range changes {
+			if change.Kind != repository.Deleted && change.Kind != repository.Renamed {
+				continue
 			}
-
-			cmds := make([]tea.Cmd, len(m.inputs))
-			for i := 0; i <= len(m.inputs)-1; i++ {
-				if i == m.focusIndex {
-					// Set focused state
-					cmds[i] = m.inputs[i].Focus()
-					m.inputs[i].PromptStyle = focusedStyle
-					m.inputs[i].TextStyle = focusedStyle
-					continue
-				}
-				// Remove focused state
-				m.inputs[i].Blur()
-				m.inputs[i].PromptStyle = noStyle
-				m.inputs[i].TextStyle = noStyle
+			_, err := repo.PropagateChange(vRepo, change)
+			if err != nil {
+				log.Fatalf("Error recording %s of %s: %v", change.Kind, change.Path, err)
 			}
-
-			return m, tea.Batch(cmds...)
 		}
-	}
-
-	// Handle character input and blinking
-	cmd := m.updateInputs(msg)
-
-	return m, cmd
-}
-
-func (m *model) updateInputs(msg tea.Msg) tea.Cmd {
-	cmds := make([]tea.Cmd, len(m.inputs))
-
-	// Only text inputs with Focus() set will respond, so it's safe to simply
-	// update all of them here without any further logic.
-	for i := range m.inputs {
-		m.inputs[i], cmds[i] = m.inputs[i].Update(msg)
-	}
 
-	return tea.Batch(cmds...)
-}
-
-func (m model) View() string {
-	switch m.state {
-	case stateInit:
-		var b strings.Builder
-
-		for i := range m.inputs {
-			b.WriteString(m.inputs[i].View())
-			if i < len(m.inputs)-1 {
-				b.WriteRune('\n')
-			}
+		err = vRepo.CreateEmptyBranchesForChangedFiles()
+		if err != nil {
+			log.Fatalf("Error creating empty branches in integration submodule: %v", err)
 		}
 
-		button := &blurredButton
-		if m.focusIndex == len(m.inputs) {
-			button = &focusedButton
+		err = vRepo.CommitChangedFiles()
+		if err != nil {
+			log.Fatalf("Error committing changes in integration submodule: %v", err)
 		}
-		fmt.Fprintf(&b, "\n\n%s\n\n", *button)
-
-		// b.WriteString(helpStyle.Render("cursor mode is "))
-		// b.WriteString(cursorModeHelpStyle.Render(m.cursorMode.String()))
-		// b.WriteString(helpStyle.Render(" (ctrl+r to change style)"))
-
-		return b.String()
-
-	case end:
-		return "Goodbye!"
-
-	default:
-		return "Unknown state"
-	}
-
-}
-
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: ctrls <command>")
-		os.Exit(1)
-	}
-
-	cmd := os.Args[1]
 
-	if cmd == "cleanbranch" {
-		repo := repository.New()
-		err := repo.PlainOpen(".")
+		changeset, err := vRepo.CommitChangeset()
 		if err != nil {
-			fmt.Println("You are not in a Git repository.")
-			return
+			log.Fatalf("Error committing changeset: %v", err)
+		}
+		if !changeset.IsZero() {
+			fmt.Println("Changeset committed:", changeset)
 		}
-		repo.CreateEmptyBranchesForChangedFiles()
-		// vPath, err := repo.IntegrationSubmodulePath()
-		// if err != nil {
-		// 	log.Fatalf("Error getting integration submodule path: %v", err)
-		// }
-		// fmt.Printf("Integration submodule path: %s\n", vPath)
-		// vRepo := repository.New()
-		// err = vRepo.PlainOpen(vPath)
-		// if err != nil {
-		// 	log.Fatalf("Error opening integration submodule: %v", err)
-		// }
-		// err = vRepo.CreateEmptyBranchesForChangedFiles()
-		// if err != nil {
-		// 	log.Fatalf("Error creating empty branches in integration submodule: %v", err)
-		// }
-
-		// err = vRepo.CommitChangedFiles()
-		// if err != nil {
-		// 	log.Fatalf("Error committing changes in integration submodule: %v", err)
-		// }
 
 	} else if cmd == "userinfo" {
 		repo := repository.New()
@@ -403,12 +250,7 @@ func main() {
 		fmt.Printf("Git user name: %s\n", name)
 		fmt.Printf("Git user email: %s\n", email)
 	} else if cmd == "changes" {
-		repo := repository.New()
-		err := repo.PlainOpen(".")
-		if err != nil {
-			fmt.Println("You are not in a Git repository.")
-			return
-		}
+		repo, _ := openProject()
 
 		files, err := repo.GetChangedFiles()
 		if err != nil {
@@ -420,16 +262,7 @@ func main() {
 			fmt.Println(entry)
 		}
 
-		vPath, err := repo.IntegrationSubmodulePath()
-		if err != nil {
-			log.Fatalf("Error getting integration submodule path: %v", err)
-		}
-
-		vRepo := repository.New()
-		err = vRepo.PlainOpen(vPath)
-		if err != nil {
-			log.Fatalf("Error opening integration submodule: %v", err)
-		}
+		_, vRepo := openRepositories()
 
 		files, err = vRepo.GetChangedFiles()
 		if err != nil {
@@ -442,12 +275,7 @@ func main() {
 		}
 
 	} else if cmd == "copy" {
-		repo := repository.New()
-		err := repo.PlainOpen(".")
-		if err != nil {
-			fmt.Println("You are not in a Git repository.")
-			return
-		}
+		repo, _ := openProject()
 
 		files, err := repo.GetChangedFiles()
 		if err != nil {
@@ -465,73 +293,64 @@ func main() {
 			return
 		}
 	} else if cmd == "removeintegration" {
-		repo := repository.New()
-		err := repo.PlainOpen(".")
-		if err != nil {
-			fmt.Println("You are not in a Git repository.")
-			return
-		}
-		err = repo.RemoveSubmodule()
+		repo, cfg := openProject()
+		err := repo.RemoveSubmodule()
 		if err != nil {
 			fmt.Println("Error removing submodule:", err)
 			return
 		}
 
 		fmt.Printf("\nRun th cmds in a clean branch and merge with your main\n\n")
-		fmt.Printf("\ngit add .gitmodules versionctrls-integration")
-		fmt.Printf("\ngit commit -m 'Remove versionctrls-integration'\n\n")
+		fmt.Printf("\ngit add .gitmodules %s", cfg.Integration.Path)
+		fmt.Printf("\ngit commit -m 'Remove %s'\n\n", cfg.Integration.Path)
 
-	} else if cmd == "init" {
-		repo := repository.New()
-		err := repo.PlainOpen(".")
-		if err != nil {
-			fmt.Println("You are not in a Git repository.")
-			return
-		}
+	} else if cmd == "watch" {
+		runWatch(os.Args[2:])
 
-		rootPath, err := repo.GetRepoRoot()
-		if err != nil {
-			fmt.Println("You are not in the root of the Git repository.")
-		}
+	} else if cmd == "log" {
+		runLog(os.Args[2:])
 
-		p := tea.NewProgram(initialModel())
-		m, err := p.Run()
-		if err != nil {
-			fmt.Printf("Alas, there's been an error: %v", err)
-			os.Exit(1)
-		}
+	} else if cmd == "restore" {
+		runRestore(os.Args[2:])
 
-		finalModel := m.(model)
-		if finalModel.initialized {
-			apiKey := finalModel.inputs[0].Value()
-			err := os.WriteFile(secretFilePath, []byte(apiKey), 0600)
-			if err != nil {
-				fmt.Println("Error saving API key:", err)
-				return
-			}
+	} else if cmd == "recover" {
+		runRecover(os.Args[2:])
 
-			exists, err := repo.SubmoduleExists("versionctrls-integration")
-			if err != nil {
-				fmt.Println("Error checking for submodule:", err)
-				return
-			}
-			if !exists {
-				submoduleURL := "https://github.com/renatonmag/gitexperimentsintegration.git"
-				submodulePath := "versionctrls-integration"
-				err := repo.AddSubmodule(submoduleURL, submodulePath)
-				if err != nil {
-					fmt.Println("Error adding submodule:", err)
-					return
-				}
-				// fmt.Println(output)
-			} else {
-				fmt.Println("Versionctrls is already initialized.")
-			}
+	} else if cmd == "scan" {
+		runScan(os.Args[2:])
 
-			fmt.Printf("\nVersionctrls initialized at: %s\n", rootPath)
-			fmt.Printf("\nCommit the changes to .gitmodules and versionctrls-integration folder.\n\n")
-			fmt.Printf("\nJust hit ctrl+s and you're good. Your files are safe forever.")
-		}
+	} else if cmd == "show" {
+		runShow(os.Args[2:])
+
+	} else if cmd == "key" {
+		runKey(os.Args[2:])
+
+	} else if cmd == "changeset" {
+		runChangeset(os.Args[2:])
+
+	} else if cmd == "checkpoint" {
+		runCheckpoint(os.Args[2:])
+
+	} else if cmd == "diff" {
+		runDiff(os.Args[2:])
+
+	} else if cmd == "migrate-refs" {
+		runMigrateRefs()
+
+	} else if cmd == "auth" {
+		runAuth(os.Args[2:])
+
+	} else if cmd == "push" {
+		runPush(os.Args[2:])
+
+	} else if cmd == "pull" {
+		runPull(os.Args[2:])
+
+	} else if cmd == "status" {
+		runStatus()
+
+	} else if cmd == "init" {
+		runInit(os.Args[2:])
 	} else {
 		fmt.Printf("Unknown command: %s\n", cmd)
 		os.Exit(1)
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+)
+
+// runMigrateRefs moves snapshot branches out of refs/heads into the private namespace
+func runMigrateRefs() {
+	repo, vRepo := openRepositories()
+
+	migrations, err := vRepo.MigrateSnapshotBranches()
+	for _, m := range migrations {
+		if m.Err != nil {
+			fmt.Printf("Could not move %s: %v\n", m.Branch, m.Err)
+			continue
+		}
+		fmt.Printf("Moved %s to %s (%s)\n", m.Branch, m.Ref, m.Path)
+	}
+	if err != nil {
+		fmt.Println("Error enabling the snapshot namespace:", err)
+		os.Exit(1)
+	}
+
+	// Everyone else on the project has to look in the namespace too
+	rootPath, err := repo.GetRepoRoot()
+	if err == nil {
+		var project config.Config
+		project, err = config.LoadProject(rootPath)
+		if err == nil {
+			project.Integration.Refs = config.RefsNamespaced
+			err = config.SaveProject(rootPath, project)
+		}
+	}
+	if err != nil {
+		fmt.Println("Error saving configuration:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nNew snapshots are stored under refs/versionctrls/files.\n")
+	fmt.Printf("Commit %s so that everyone else on the project looks there too.\n", config.ProjectFile)
+	fmt.Printf("Branches already pushed stay on the remote until you delete them there.\n")
+}
@@ -0,0 +1,63 @@
+// Package chunker splits content into content-defined chunks with a gear
+// rolling hash, so that an edit only changes the chunks around it and the
+// rest are shared between versions.
+package chunker
+
+const (
+	// MinSize is the smallest chunk cut, except at the end of the content
+	MinSize = 64 * 1024
+	// MaxSize is the largest chunk cut
+	MaxSize = 1024 * 1024
+	// cutMask has 18 bits set, for chunks of about 256 KiB past MinSize
+	cutMask = 1<<18 - 1
+)
+
+// gear maps each byte to a random 64 bit value. It comes from a fixed seed
+// since the chunk boundaries of saved versions must never change.
+var gear = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x76657273696f6e63) // "versionc"
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// Split cuts data into chunks of MinSize to MaxSize bytes wherever the
+// rolling hash of the bytes before the cut matches cutMask. The chunks share
+// data's memory.
+func Split(data []byte) [][]byte {
+	var chunks [][]byte
+	for len(data) > 0 {
+		n := cut(data)
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return chunks
+}
+
+// cut returns the length of the first chunk of data
+func cut(data []byte) int {
+	if len(data) <= MinSize {
+		return len(data)
+	}
+
+	limit := len(data)
+	if limit > MaxSize {
+		limit = MaxSize
+	}
+
+	var hash uint64
+	for i := MinSize; i < limit; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&cutMask == 0 {
+			return i + 1
+		}
+	}
+	return limit
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/renatonmag/versionctrls-cli/pkg/ignore"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+const (
+	// ProjectFile is the configuration committed at the repository root
+	ProjectFile = ".versionctrls.toml"
+	// UserFile holds per-user overrides in utils.ConfigDir
+	UserFile = "config.toml"
+
+	// DefaultIntegrationPath is where the integration submodule goes when
+	// nothing else is configured
+	DefaultIntegrationPath = "versionctrls-integration"
+)
+
+// Config is the versionctrls configuration of a project
+type Config struct {
+	Integration Integration `toml:"integration"`
+	Ignore      Ignore      `toml:"ignore,omitempty"`
+	LargeFiles  LargeFiles  `toml:"large_files,omitempty"`
+	Secrets     Secrets     `toml:"secrets,omitempty"`
+	Encryption  Encryption  `toml:"encryption,omitempty"`
+}
+
+// Where per-file snapshots are kept in the integration repository
+const (
+	// RefsNamespaced keeps them under refs/versionctrls/files
+	RefsNamespaced = "namespaced"
+	// RefsBranches keeps them as branches, as installs before the
+	// namespace did
+	RefsBranches = "branches"
+)
+
+// Version numbering schemes
+const (
+	// VersioningSemver bumps the patch number: 0.1.0, 0.1.1, 0.1.2...
+	VersioningSemver = "semver"
+	// VersioningCounter counts versions: 1, 2, 3...
+	VersioningCounter = "counter"
+)
+
+// Integration describes the integration repository. Refs and Versioning
+// are shared by everyone working on the project, so they live here rather
+// than in the git config of one clone.
+type Integration struct {
+	URL        string `toml:"url,omitempty"`
+	Path       string `toml:"path,omitempty"`
+	Refs       string `toml:"refs,omitempty"`
+	Versioning string `toml:"versioning,omitempty"`
+}
+
+// Ignore lists the files that are never versioned
+type Ignore struct {
+	Presets []string `toml:"presets,omitempty"`
+}
+
+// Load reads the configuration of the project at root. Values in the user's
+// config.toml override the ones in the project's .versionctrls.toml, which
+// override the defaults. Missing files are not an error. The ref layout,
+// version scheme and encryption salt describe what is already stored in the
+// integration repository, so only the project sets them.
+func Load(root string) (Config, error) {
+	cfg := Config{Integration: Integration{Path: DefaultIntegrationPath}}
+
+	if err := merge(&cfg, filepath.Join(root, ProjectFile), true); err != nil {
+		return Config{}, err
+	}
+
+	if dir, err := utils.ConfigDir(); err == nil {
+		if err := merge(&cfg, filepath.Join(dir, UserFile), false); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// LoadProject reads only the project's .versionctrls.toml
+func LoadProject(root string) (Config, error) {
+	var cfg Config
+	err := merge(&cfg, filepath.Join(root, ProjectFile), true)
+	return cfg, err
+}
+
+// SaveProject writes cfg to the project's .versionctrls.toml
+func SaveProject(root string, cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("# versionctrls project configuration, commit this file\n\n")
+	encoder := toml.NewEncoder(&b)
+	encoder.Indent = ""
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+
+	return utils.WriteFileAtomic(filepath.Join(root, ProjectFile), []byte(b.String()), 0644)
+}
+
+// Validate checks that the integration path stays inside the repository
+// and its refs and versioning are known, that every ignore preset exists,
+// that size limits make sense, that secret rules compile and that
+// encryption is set up completely
+func (c Config) Validate() error {
+	if err := c.LargeFiles.Validate(); err != nil {
+		return err
+	}
+	if err := c.Secrets.Validate(); err != nil {
+		return err
+	}
+	if err := c.Encryption.Validate(); err != nil {
+		return err
+	}
+
+	switch c.Integration.Refs {
+	case "", RefsNamespaced, RefsBranches:
+	default:
+		return fmt.Errorf("integration.refs: unknown value %q, use %s or %s", c.Integration.Refs, RefsNamespaced, RefsBranches)
+	}
+	switch c.Integration.Versioning {
+	case "", VersioningSemver, VersioningCounter:
+	default:
+		return fmt.Errorf("integration.versioning: unknown scheme %q, use %s or %s", c.Integration.Versioning, VersioningSemver, VersioningCounter)
+	}
+
+	for _, name := range c.Ignore.Presets {
+		if _, ok := ignore.LookupPreset(name); !ok {
+			return fmt.Errorf("unknown ignore preset %q", name)
+		}
+	}
+
+	path := c.Integration.Path
+	if path == "" {
+		return nil
+	}
+
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("integration path %q must be a directory inside the repository", path)
+	}
+	if filepath.Base(clean) == ".git" {
+		return fmt.Errorf("integration path %q cannot be a .git directory", path)
+	}
+	return nil
+}
+
+// merge overlays the values set in the file at path onto cfg. Settings
+// shared by everyone on the project are only taken from the project file.
+func merge(cfg *Config, path string, project bool) error {
+	var file Config
+	_, err := toml.DecodeFile(path, &file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	if file.Integration.URL != "" {
+		cfg.Integration.URL = file.Integration.URL
+	}
+	if file.Integration.Path != "" {
+		cfg.Integration.Path = filepath.ToSlash(filepath.Clean(file.Integration.Path))
+	}
+	if file.Integration.Refs != "" && project {
+		cfg.Integration.Refs = file.Integration.Refs
+	}
+	if file.Integration.Versioning != "" && project {
+		cfg.Integration.Versioning = file.Integration.Versioning
+	}
+	if file.Ignore.Presets != nil {
+		cfg.Ignore.Presets = file.Ignore.Presets
+	}
+	if file.LargeFiles.MaxSize != "" {
+		cfg.LargeFiles.MaxSize = file.LargeFiles.MaxSize
+	}
+	if file.LargeFiles.Strategy != "" {
+		cfg.LargeFiles.Strategy = file.LargeFiles.Strategy
+	}
+	// Rules from later files come last and take precedence
+	cfg.LargeFiles.Rules = append(cfg.LargeFiles.Rules, file.LargeFiles.Rules...)
+	if file.Secrets.Action != "" {
+		cfg.Secrets.Action = file.Secrets.Action
+	}
+	cfg.Secrets.Rules = append(cfg.Secrets.Rules, file.Secrets.Rules...)
+	if file.Encryption.Method != "" {
+		cfg.Encryption.Method = file.Encryption.Method
+	}
+	if file.Encryption.Recipients != nil {
+		cfg.Encryption.Recipients = file.Encryption.Recipients
+	}
+	if file.Encryption.Identity != "" {
+		cfg.Encryption.Identity = file.Encryption.Identity
+	}
+	if file.Encryption.Salt != "" && project {
+		cfg.Encryption.Salt = file.Encryption.Salt
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUserFileKeepsProjectSettings(t *testing.T) {
+	root := t.TempDir()
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+
+	project := `[integration]
+url = "https://example.com/project.git"
+refs = "namespaced"
+versioning = "counter"
+
+[encryption]
+method = "passphrase"
+salt = "project"
+`
+	user := `[integration]
+url = "https://example.com/fork.git"
+refs = "branches"
+versioning = "semver"
+
+[encryption]
+identity = "~/keys.txt"
+salt = "mine"
+`
+	if err := os.WriteFile(filepath.Join(root, ProjectFile), []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(xdg, "versionctrls"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(xdg, "versionctrls", UserFile), []byte(user), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ name, got, want string }{
+		{"integration.url", cfg.Integration.URL, "https://example.com/fork.git"},
+		{"integration.refs", cfg.Integration.Refs, RefsNamespaced},
+		{"integration.versioning", cfg.Integration.Versioning, VersioningCounter},
+		{"encryption.identity", cfg.Encryption.Identity, "~/keys.txt"},
+		{"encryption.salt", cfg.Encryption.Salt, "project"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.name, tc.got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Encryption methods for the content of snapshots
+const (
+	// EncryptAge encrypts to the X25519 public keys of the recipients
+	EncryptAge = "age"
+	// EncryptPassphrase encrypts with a key derived from a passphrase
+	EncryptPassphrase = "passphrase"
+)
+
+// Encryption sets how file contents are encrypted before they are
+// committed to the integration repository. An empty method leaves them as
+// they are.
+type Encryption struct {
+	Method string `toml:"method,omitempty"`
+	// Recipients are the public keys of everyone who may read the
+	// versions, for the age method
+	Recipients []string `toml:"recipients,omitempty"`
+	// Identity is the file with this user's private keys, usually set in
+	// the user's config.toml
+	Identity string `toml:"identity,omitempty"`
+	// Salt goes into the key derived from the passphrase, for the
+	// passphrase method
+	Salt string `toml:"salt,omitempty"`
+}
+
+// Enabled reports whether contents are encrypted
+func (e Encryption) Enabled() bool {
+	return e.Method != ""
+}
+
+// Validate checks the method and that the age method has recipients
+func (e Encryption) Validate() error {
+	switch e.Method {
+	case "":
+	case EncryptPassphrase:
+		if e.Salt == "" {
+			return fmt.Errorf("encryption.salt: the %s method needs a salt, see ctrls key passphrase", EncryptPassphrase)
+		}
+	case EncryptAge:
+		if len(e.Recipients) == 0 {
+			return fmt.Errorf("encryption.recipients: the %s method needs at least one recipient, see ctrls key generate", EncryptAge)
+		}
+		for _, recipient := range e.Recipients {
+			if !strings.HasPrefix(recipient, "age1") {
+				return fmt.Errorf("encryption.recipients: %q is not an age public key", recipient)
+			}
+		}
+	default:
+		return fmt.Errorf("encryption.method: unknown method %q, use %s or %s", e.Method, EncryptAge, EncryptPassphrase)
+	}
+	return nil
+}
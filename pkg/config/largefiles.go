@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// Strategies for files over their size limit
+const (
+	// StrategySkip leaves the file out with a warning
+	StrategySkip = "skip"
+	// StrategyLFS stores a Git LFS pointer in the snapshot and the content
+	// in the LFS object store of the integration repository
+	StrategyLFS = "lfs"
+	// StrategyChunk splits the content into content-defined chunks, so that
+	// versions share the chunks they have in common
+	StrategyChunk = "chunk"
+)
+
+// DefaultMaxSize is the size limit of files no rule applies to
+const DefaultMaxSize = "1MiB"
+
+// LargeFiles sets how big a file may get before Strategy applies to it.
+// Rules refine both for the files matching their pattern, the last matching
+// rule winning.
+type LargeFiles struct {
+	MaxSize  string     `toml:"max_size,omitempty"`
+	Strategy string     `toml:"strategy,omitempty"`
+	Rules    []SizeRule `toml:"rule,omitempty"`
+}
+
+// SizeRule is a size limit and strategy for the files matching a
+// .gitignore style pattern. Empty fields keep the defaults.
+type SizeRule struct {
+	Pattern  string `toml:"pattern"`
+	MaxSize  string `toml:"max_size,omitempty"`
+	Strategy string `toml:"strategy,omitempty"`
+}
+
+// Policy returns the size limit in bytes and the strategy for file, a slash
+// separated path relative to the repository root
+func (l LargeFiles) Policy(file string) (int64, string) {
+	maxSize, strategy := l.MaxSize, l.Strategy
+	if maxSize == "" {
+		maxSize = DefaultMaxSize
+	}
+	if strategy == "" {
+		strategy = StrategySkip
+	}
+
+	parts := strings.Split(file, "/")
+	for _, rule := range l.Rules {
+		if gitignore.ParsePattern(rule.Pattern, nil).Match(parts, false) != gitignore.Exclude {
+			continue
+		}
+		if rule.MaxSize != "" {
+			maxSize = rule.MaxSize
+		}
+		if rule.Strategy != "" {
+			strategy = rule.Strategy
+		}
+	}
+
+	// Validate makes sure sizes parse
+	limit, _ := utils.ParseSize(maxSize)
+	return limit, strategy
+}
+
+// Validate checks the sizes and strategies
+func (l LargeFiles) Validate() error {
+	if err := validateSize(l.MaxSize, "large_files.max_size"); err != nil {
+		return err
+	}
+	if err := validateStrategy(l.Strategy, "large_files.strategy"); err != nil {
+		return err
+	}
+
+	for _, rule := range l.Rules {
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return fmt.Errorf("large_files.rule without a pattern")
+		}
+		if err := validateSize(rule.MaxSize, "max_size of "+rule.Pattern); err != nil {
+			return err
+		}
+		if err := validateStrategy(rule.Strategy, "strategy of "+rule.Pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSize(size, name string) error {
+	if size == "" {
+		return nil
+	}
+	if _, err := utils.ParseSize(size); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func validateStrategy(strategy, name string) error {
+	switch strategy {
+	case "", StrategySkip, StrategyLFS, StrategyChunk:
+		return nil
+	}
+	return fmt.Errorf("%s: unknown strategy %q, use %s, %s or %s", name, strategy, StrategySkip, StrategyLFS, StrategyChunk)
+}
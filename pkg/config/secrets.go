@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Actions taken on files in which the secret scanner finds something
+const (
+	// SecretsBlock leaves the file out with a warning
+	SecretsBlock = "block"
+	// SecretsRedact versions the file with every match replaced by a marker
+	SecretsRedact = "redact"
+	// SecretsOff turns the scanner off
+	SecretsOff = "off"
+)
+
+// Secrets sets what happens to files that look like they hold credentials.
+// Rules add patterns to the built-in ones.
+type Secrets struct {
+	Action string       `toml:"action,omitempty"`
+	Rules  []SecretRule `toml:"rule,omitempty"`
+}
+
+// SecretRule is a regular expression for a kind of secret. When it has a
+// group named secret only that group is the secret, otherwise the whole
+// match is.
+type SecretRule struct {
+	Name    string `toml:"name"`
+	Pattern string `toml:"pattern"`
+}
+
+// Validate checks the action and that every rule compiles
+func (s Secrets) Validate() error {
+	switch s.Action {
+	case "", SecretsBlock, SecretsRedact, SecretsOff:
+	default:
+		return fmt.Errorf("secrets.action: unknown action %q, use %s, %s or %s", s.Action, SecretsBlock, SecretsRedact, SecretsOff)
+	}
+
+	for _, rule := range s.Rules {
+		if strings.TrimSpace(rule.Name) == "" {
+			return fmt.Errorf("secrets.rule without a name")
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("pattern of secrets rule %s: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package credentials
+
+import "os"
+
+const (
+	// TokenEnv holds a token used for any host
+	TokenEnv = "VERSIONCTRLS_TOKEN"
+	// UsernameEnv optionally holds the user name to go with TokenEnv
+	UsernameEnv = "VERSIONCTRLS_USERNAME"
+)
+
+// hostTokenEnvs are the variables hosting providers' own tools read tokens from
+var hostTokenEnvs = map[string]string{
+	"github.com": "GITHUB_TOKEN",
+	"gitlab.com": "GITLAB_TOKEN",
+}
+
+// EnvStore reads credentials from environment variables
+type EnvStore struct {
+	getenv func(string) string
+}
+
+// NewEnvStore returns a store backed by the process environment
+func NewEnvStore() *EnvStore {
+	return &EnvStore{getenv: os.Getenv}
+}
+
+// Name identifies the store in messages
+func (s *EnvStore) Name() string {
+	return "environment"
+}
+
+// Get returns the token from VERSIONCTRLS_TOKEN, or from the variable the
+// host's own tools use, such as GITHUB_TOKEN
+func (s *EnvStore) Get(host string) (Credential, error) {
+	if token := s.getenv(TokenEnv); token != "" {
+		return Credential{Username: s.getenv(UsernameEnv), Secret: token}, nil
+	}
+
+	if name, ok := hostTokenEnvs[host]; ok {
+		if token := s.getenv(name); token != "" {
+			return Credential{Secret: token}, nil
+		}
+	}
+
+	return Credential{}, ErrNotFound
+}
+
+// Set always fails since the environment cannot be written for later runs
+func (s *EnvStore) Set(host string, cred Credential) error {
+	return ErrReadOnly
+}
+
+// Delete always fails since the environment cannot be written for later runs
+func (s *EnvStore) Delete(host string) error {
+	return ErrReadOnly
+}
@@ -0,0 +1,252 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv holds the passphrase the credentials file is encrypted with
+const PassphraseEnv = "VERSIONCTRLS_PASSPHRASE"
+
+const (
+	credentialsFile = "credentials.enc"
+	keyFile         = "credentials.key"
+
+	kdfScrypt  = "scrypt"
+	kdfKeyFile = "keyfile"
+)
+
+// FileStore keeps credentials in an AES-GCM encrypted file in the user's
+// config directory, with a key derived from a passphrase. That keeps them
+// safe in backups and copies of the directory, but not from programs that
+// run as the user while the passphrase is in the environment.
+//
+// Earlier versions kept a random key in credentials.key next to the file
+// instead, which only keeps the API keys from being read by accident since
+// whoever can read one file can read the other. Such files are still read,
+// and are encrypted with the passphrase the next time they are written.
+type FileStore struct {
+	dir string
+	// passphrase returns the passphrase, asking twice when confirm is set
+	// because the file is about to be created
+	passphrase func(confirm bool) (string, error)
+	cached     string
+	// kdf is how the file was encrypted when it was last loaded
+	kdf string
+}
+
+// envelope is the on-disk format of the credentials file
+type envelope struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt,omitempty"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// NewFileStore returns the store in $XDG_CONFIG_HOME/versionctrls.
+// passphrase is only called when the file is read or written.
+func NewFileStore(passphrase func(confirm bool) (string, error)) (*FileStore, error) {
+	dir, err := utils.ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewFileStoreAt(dir, passphrase), nil
+}
+
+// NewFileStoreAt returns a store in dir
+func NewFileStoreAt(dir string, passphrase func(confirm bool) (string, error)) *FileStore {
+	return &FileStore{dir: dir, passphrase: passphrase}
+}
+
+// Name identifies the store in messages
+func (s *FileStore) Name() string {
+	return "encrypted file " + filepath.Join(s.dir, credentialsFile)
+}
+
+// Get returns the credential saved for host
+func (s *FileStore) Get(host string) (Credential, error) {
+	creds, err := s.load()
+	if err != nil {
+		return Credential{}, err
+	}
+
+	cred, ok := creds[host]
+	if !ok {
+		return Credential{}, ErrNotFound
+	}
+	return cred, nil
+}
+
+// Set saves the credential for host
+func (s *FileStore) Set(host string, cred Credential) error {
+	creds, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	creds[host] = cred
+	return s.save(creds)
+}
+
+// Delete forgets the credential for host
+func (s *FileStore) Delete(host string) error {
+	creds, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := creds[host]; !ok {
+		return ErrNotFound
+	}
+	delete(creds, host)
+	return s.save(creds)
+}
+
+func (s *FileStore) load() (map[string]Credential, error) {
+	raw, err := os.ReadFile(filepath.Join(s.dir, credentialsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]Credential{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, fmt.Errorf("corrupt credentials file: %w", err)
+	}
+
+	key, err := s.key(env.KDF, env.Salt, false)
+	if err != nil {
+		return nil, err
+	}
+	s.kdf = env.KDF
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := gcm.Open(nil, env.Nonce, env.Data, nil)
+	if err != nil {
+		return nil, errors.New("could not decrypt credentials file, wrong passphrase?")
+	}
+
+	creds := map[string]Credential{}
+	if err := json.Unmarshal(plain, &creds); err != nil {
+		return nil, fmt.Errorf("corrupt credentials file: %w", err)
+	}
+	return creds, nil
+}
+
+func (s *FileStore) save(creds map[string]Credential) error {
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+
+	env := envelope{Version: 1, KDF: kdfScrypt, Salt: make([]byte, 16)}
+	if _, err := rand.Read(env.Salt); err != nil {
+		return err
+	}
+
+	// A passphrase that nothing was encrypted with yet is asked for twice
+	key, err := s.key(env.KDF, env.Salt, s.kdf != kdfScrypt)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	env.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return err
+	}
+	env.Data = gcm.Seal(nil, env.Nonce, plain, nil)
+
+	raw, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(s.dir, credentialsFile), raw, 0600); err != nil {
+		return err
+	}
+
+	// The key of earlier versions has nothing left to open
+	err = os.Remove(filepath.Join(s.dir, keyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return err
+}
+
+// key derives the encryption key for kdf
+func (s *FileStore) key(kdf string, salt []byte, confirm bool) ([]byte, error) {
+	switch kdf {
+	case kdfScrypt:
+		passphrase, err := s.readPassphrase(confirm)
+		if err != nil {
+			return nil, err
+		}
+		return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+
+	case kdfKeyFile:
+		path := filepath.Join(s.dir, keyFile)
+		key, err := os.ReadFile(path)
+		if err == nil && len(key) == 32 {
+			return key, nil
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("missing or invalid key file %s", path)
+
+	default:
+		return nil, fmt.Errorf("unknown key derivation %q in credentials file", kdf)
+	}
+}
+
+// readPassphrase returns the passphrase, asking for it once per run
+func (s *FileStore) readPassphrase(confirm bool) (string, error) {
+	if s.cached != "" {
+		return s.cached, nil
+	}
+	if s.passphrase == nil {
+		return "", fmt.Errorf("the credentials file needs a passphrase, set %s", PassphraseEnv)
+	}
+
+	passphrase, err := s.passphrase(confirm)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("empty passphrase")
+	}
+	s.cached = passphrase
+	return passphrase, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
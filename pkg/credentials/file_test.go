@@ -0,0 +1,83 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreNeedsPassphrase(t *testing.T) {
+	dir := t.TempDir()
+
+	s := NewFileStoreAt(dir, nil)
+	if err := s.Set("github.com", Credential{Secret: "token"}); err == nil {
+		t.Fatal("saved credentials without a passphrase")
+	}
+	if _, err := os.Stat(filepath.Join(dir, credentialsFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("credentials file written without a passphrase: %v", err)
+	}
+
+	var confirmed []bool
+	s = NewFileStoreAt(dir, func(confirm bool) (string, error) {
+		confirmed = append(confirmed, confirm)
+		return "hunter2", nil
+	})
+	if err := s.Set("github.com", Credential{Secret: "token"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(confirmed) != 1 || !confirmed[0] {
+		t.Errorf("asked for the passphrase of a new file with confirm %v, want it once and confirmed", confirmed)
+	}
+
+	s = NewFileStoreAt(dir, func(bool) (string, error) { return "wrong", nil })
+	if _, err := s.Get("github.com"); err == nil {
+		t.Error("read credentials with the wrong passphrase")
+	}
+}
+
+func TestFileStoreMovesOffKeyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// A file encrypted with the random key of earlier versions
+	key := make([]byte, 32)
+	rand.Read(key)
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := envelope{Version: 1, KDF: kdfKeyFile, Nonce: make([]byte, gcm.NonceSize())}
+	plain, _ := json.Marshal(map[string]Credential{"github.com": {Secret: "old"}})
+	env.Data = gcm.Seal(nil, env.Nonce, plain, nil)
+	raw, _ := json.Marshal(env)
+	os.WriteFile(filepath.Join(dir, keyFile), key, 0600)
+	os.WriteFile(filepath.Join(dir, credentialsFile), raw, 0600)
+
+	var confirmed []bool
+	s := NewFileStoreAt(dir, func(confirm bool) (string, error) {
+		confirmed = append(confirmed, confirm)
+		return "hunter2", nil
+	})
+	cred, err := s.Get("github.com")
+	if err != nil || cred.Secret != "old" {
+		t.Fatalf("Get = %+v, %v, want the old credential", cred, err)
+	}
+	if err := s.Set("example.com", Credential{Secret: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(confirmed) != 1 || !confirmed[0] {
+		t.Errorf("asked for the first passphrase with confirm %v, want it once and confirmed", confirmed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, keyFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("key file left next to the credentials: %v", err)
+	}
+
+	s = NewFileStoreAt(dir, func(bool) (string, error) { return "hunter2", nil })
+	for host, want := range map[string]string{"github.com": "old", "example.com": "new"} {
+		if cred, err := s.Get(host); err != nil || cred.Secret != want {
+			t.Errorf("Get(%s) = %+v, %v, want %s", host, cred, err, want)
+		}
+	}
+}
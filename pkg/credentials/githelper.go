@@ -0,0 +1,114 @@
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// GitHelperStore uses whatever credential helpers git is configured with,
+// through the `git credential` protocol
+type GitHelperStore struct {
+	protocol string
+}
+
+// NewGitHelperStore returns a store that talks to git's credential helpers
+// about https hosts
+func NewGitHelperStore() *GitHelperStore {
+	return &GitHelperStore{protocol: "https"}
+}
+
+// Name identifies the store in messages
+func (s *GitHelperStore) Name() string {
+	return "git credential helper"
+}
+
+// Configured reports whether git has any credential helper to keep
+// credentials in
+func (s *GitHelperStore) Configured() bool {
+	out, err := exec.Command("git", "config", "--get-regexp", `^credential\.(.+\.)?helper$`).Output()
+	if err != nil {
+		// git exits with 1 when nothing matches
+		return false
+	}
+
+	configured := false
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// An empty helper resets the list configured before it
+		_, helper, _ := strings.Cut(scanner.Text(), " ")
+		configured = strings.TrimSpace(helper) != ""
+	}
+	return configured
+}
+
+// Get asks the configured helpers for a credential for host, without ever
+// prompting on the terminal
+func (s *GitHelperStore) Get(host string) (Credential, error) {
+	out, err := s.run("fill", host, Credential{})
+	if err != nil {
+		// git fails when no helper knows the host and it may not prompt
+		return Credential{}, ErrNotFound
+	}
+
+	var cred Credential
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "username":
+			cred.Username = value
+		case "password":
+			cred.Secret = value
+		}
+	}
+
+	if cred.Secret == "" {
+		return Credential{}, ErrNotFound
+	}
+	return cred, nil
+}
+
+// Set hands the credential to the helpers to keep
+func (s *GitHelperStore) Set(host string, cred Credential) error {
+	_, err := s.run("approve", host, cred)
+	return err
+}
+
+// Delete asks the helpers to forget the credential for host
+func (s *GitHelperStore) Delete(host string) error {
+	cred, err := s.Get(host)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.run("reject", host, cred)
+	return err
+}
+
+func (s *GitHelperStore) run(action, host string, cred Credential) ([]byte, error) {
+	var input strings.Builder
+	fmt.Fprintf(&input, "protocol=%s\nhost=%s\n", s.protocol, host)
+	if cred.Username != "" || cred.Secret != "" {
+		fmt.Fprintf(&input, "username=%s\npassword=%s\n", cred.UsernameOrDefault(), cred.Secret)
+	}
+	input.WriteString("\n")
+
+	cmd := exec.Command("git", "credential", action)
+	cmd.Stdin = strings.NewReader(input.String())
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git credential %s: %v: %s", action, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
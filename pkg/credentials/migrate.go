@@ -0,0 +1,30 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// LegacyKeyFile is where versions before credential stores saved the API key
+const LegacyKeyFile = "apikey.txt"
+
+// MigrateLegacyKeyFile moves an API key left in path by older versions into
+// store under host and removes the file. It reports whether there was a file.
+func MigrateLegacyKeyFile(path, host string, store Store) (bool, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if token := strings.TrimSpace(string(raw)); token != "" {
+		if err := store.Set(host, Credential{Secret: token}); err != nil {
+			return false, err
+		}
+	}
+
+	return true, os.Remove(path)
+}
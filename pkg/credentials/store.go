@@ -0,0 +1,157 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Credential is what is needed to authenticate against a host
+type Credential struct {
+	Username string `json:"username,omitempty"`
+	Secret   string `json:"secret"`
+}
+
+// UsernameOrDefault returns the user name, or the one hosting providers
+// accept along with a token when none was given
+func (c Credential) UsernameOrDefault() string {
+	if c.Username == "" {
+		return "x-access-token"
+	}
+	return c.Username
+}
+
+// Store keeps credentials per host
+type Store interface {
+	// Name identifies the store in messages
+	Name() string
+	// Get returns the credential for host or ErrNotFound
+	Get(host string) (Credential, error)
+	// Set saves the credential for host, replacing any previous one
+	Set(host string, cred Credential) error
+	// Delete forgets the credential for host
+	Delete(host string) error
+}
+
+var (
+	// ErrNotFound is returned when a store has no credential for a host
+	ErrNotFound = errors.New("no credential found")
+	// ErrReadOnly is returned when writing to a store that cannot be written
+	ErrReadOnly = errors.New("credential store is read-only")
+)
+
+// StoreEnv selects the store `ctrls auth login` writes to
+const StoreEnv = "VERSIONCTRLS_CREDENTIAL_STORE"
+
+// New returns the writable store called name: "file" or "git". Without a
+// name it is git's credential helper when one is configured, and otherwise
+// the file encrypted with the passphrase that passphrase returns.
+func New(name string, passphrase func(confirm bool) (string, error)) (Store, error) {
+	switch name {
+	case "":
+		if git := NewGitHelperStore(); git.Configured() {
+			return git, nil
+		}
+		return NewFileStore(passphrase)
+	case "file":
+		return NewFileStore(passphrase)
+	case "git":
+		return NewGitHelperStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown credential store %q", name)
+	}
+}
+
+// Default returns the store used to look credentials up: environment
+// variables first, then the encrypted file, then git's credential helpers.
+// Writes go to the store named by VERSIONCTRLS_CREDENTIAL_STORE, or to the
+// one New picks without a name.
+func Default(passphrase func(confirm bool) (string, error)) (*Chain, error) {
+	file, err := NewFileStore(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	git := NewGitHelperStore()
+
+	stores := []Store{NewEnvStore(), file, git}
+
+	var writable Store
+	switch strings.TrimSpace(os.Getenv(StoreEnv)) {
+	case "git":
+		writable = git
+	case "file":
+		writable = file
+	default:
+		writable = file
+		if git.Configured() {
+			writable = git
+		}
+	}
+
+	return &Chain{Stores: stores, Writable: writable}, nil
+}
+
+// Chain looks credentials up in several stores in order
+type Chain struct {
+	Stores   []Store
+	Writable Store
+}
+
+// Name returns the names of the stores in the chain
+func (c *Chain) Name() string {
+	names := make([]string, len(c.Stores))
+	for i, s := range c.Stores {
+		names[i] = s.Name()
+	}
+	return strings.Join(names, ", ")
+}
+
+// Get returns the credential from the first store that has one for host
+func (c *Chain) Get(host string) (Credential, error) {
+	cred, _, err := c.Find(host)
+	return cred, err
+}
+
+// Find returns the credential for host along with the store it came from.
+// A store that fails, such as a credentials file without its passphrase,
+// does not keep the later ones from being asked; its error is only returned
+// when no store has a credential.
+func (c *Chain) Find(host string) (Credential, Store, error) {
+	var errs []error
+	for _, s := range c.Stores {
+		cred, err := s.Get(host)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		return cred, s, nil
+	}
+	if len(errs) > 0 {
+		return Credential{}, nil, errors.Join(errs...)
+	}
+	return Credential{}, nil, ErrNotFound
+}
+
+// Set saves the credential in the writable store
+func (c *Chain) Set(host string, cred Credential) error {
+	if c.Writable == nil {
+		return ErrReadOnly
+	}
+	return c.Writable.Set(host, cred)
+}
+
+// Delete forgets the credential for host in every store that can be written
+func (c *Chain) Delete(host string) error {
+	var errs []error
+	for _, s := range c.Stores {
+		err := s.Delete(host)
+		if err != nil && !errors.Is(err, ErrReadOnly) && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
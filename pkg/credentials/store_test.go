@@ -0,0 +1,41 @@
+package credentials
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChainSkipsLockedStores(t *testing.T) {
+	dir := t.TempDir()
+	file := NewFileStoreAt(dir, func(bool) (string, error) { return "hunter2", nil })
+	if err := file.Set("github.com", Credential{Secret: "from-file"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same file without a passphrase, as in a run without a terminal
+	locked := NewFileStoreAt(dir, nil)
+	env := &EnvStore{getenv: func(name string) string {
+		if name == "GITHUB_TOKEN" {
+			return "from-env"
+		}
+		return ""
+	}}
+
+	chain := &Chain{Stores: []Store{locked, env}}
+	cred, from, err := chain.Find("github.com")
+	if err != nil {
+		t.Fatalf("Find = %v, want the credential of the later store", err)
+	}
+	if cred.Secret != "from-env" || from != env {
+		t.Errorf("Find = %+v from %s, want the environment's", cred, from.Name())
+	}
+
+	// Without another credential the failure is reported, not hidden
+	_, _, err = chain.Find("gitlab.com")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Find of an unknown host = %v, want the locked file's error", err)
+	}
+	if _, _, err := (&Chain{Stores: []Store{env}}).Find("gitlab.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find without failing stores = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,41 @@
+package encryption
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestBech32Encode(t *testing.T) {
+	// Valid strings from BIP 173
+	data, _ := hex.DecodeString("00443214c74254b635cf84653a56d7c675be77df")
+	for _, tc := range []struct {
+		hrp  string
+		data []byte
+		want string
+	}{
+		{"A", nil, "A12UEL5L"},
+		{"abcdef", data, "abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw"},
+	} {
+		if got := bech32Encode(tc.hrp, tc.data); got != tc.want {
+			t.Errorf("bech32Encode(%q, %x) = %s, want %s", tc.hrp, tc.data, got, tc.want)
+		}
+	}
+
+	// age parses the keys and encodes them back the same way
+	for i := 0; i < 16; i++ {
+		key := make([]byte, 32)
+		for j := range key {
+			key[j] = byte(i*32 + j*7)
+		}
+		encoded := bech32Encode("AGE-SECRET-KEY-", key)
+		identity, err := age.ParseX25519Identity(encoded)
+		if err != nil {
+			t.Fatalf("age rejects %s: %v", encoded, err)
+		}
+		if identity.String() != encoded {
+			t.Errorf("age encodes the key as %s, we as %s", identity, encoded)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// IdentityFile holds this user's private keys in utils.ConfigDir, in the
+// format of age-keygen
+const IdentityFile = "identity.txt"
+
+// IdentityPath returns the identity file set in cfg, or the default one
+func IdentityPath(cfg config.Encryption) (string, error) {
+	path := cfg.Identity
+	if path == "" {
+		dir, err := utils.ConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, IdentityFile), nil
+	}
+
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, rest)
+	}
+	return path, nil
+}
+
+// ReadIdentities returns the keys in the identity file at path, newest
+// first. A missing file has none.
+func ReadIdentities(path string) ([]*age.X25519Identity, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := age.ParseIdentities(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	var identities []*age.X25519Identity
+	for _, identity := range parsed {
+		if x25519, ok := identity.(*age.X25519Identity); ok {
+			identities = append(identities, x25519)
+		}
+	}
+	return identities, nil
+}
+
+// GenerateIdentity creates a key and puts it first in the identity file at
+// path, keeping the older keys so that content encrypted to them can still
+// be read. It returns the new key.
+func GenerateIdentity(path string) (*age.X25519Identity, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# created: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# public key: %s\n", identity.Recipient())
+	fmt.Fprintf(&b, "%s\n", identity)
+	if len(existing) > 0 {
+		b.WriteString("\n")
+		b.Write(existing)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := utils.WriteFileAtomic(path, b.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
@@ -0,0 +1,218 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"golang.org/x/crypto/scrypt"
+)
+
+// header starts every age encrypted file, which is how encrypted versions
+// are told from plain ones
+const header = "age-encryption.org/v1\n"
+
+const (
+	// payloadChunk is the plaintext size of the chunks age encrypts in turn
+	payloadChunk = 64 * 1024
+	// payloadOverhead is the nonce in front of the chunks, and each chunk
+	// carries a tag of the same size
+	payloadOverhead = 16
+)
+
+// ErrEncrypted is returned when reading encrypted content without a keyring
+var ErrEncrypted = errors.New("content is encrypted, set up [encryption] in the configuration to read it")
+
+// Keyring encrypts content to the recipients of a project and decrypts it
+// with the identities of this user. A nil Keyring leaves content as it is.
+type Keyring struct {
+	recipients []age.Recipient
+	identities []age.Identity
+
+	// passphrase derives the keys the first time they are needed, so that
+	// commands that never touch content do not ask for it
+	passphrase func() (string, error)
+	salt       string
+	once       sync.Once
+	err        error
+}
+
+// Load returns the keyring for cfg, or nil when encryption is off.
+// passphrase is only called for the passphrase method.
+func Load(cfg config.Encryption, passphrase func() (string, error)) (*Keyring, error) {
+	switch cfg.Method {
+	case "":
+		return nil, nil
+
+	case config.EncryptPassphrase:
+		return &Keyring{passphrase: passphrase, salt: cfg.Salt}, nil
+
+	case config.EncryptAge:
+		k := &Keyring{}
+		for _, s := range cfg.Recipients {
+			recipient, err := age.ParseX25519Recipient(s)
+			if err != nil {
+				return nil, fmt.Errorf("encryption.recipients: %w", err)
+			}
+			k.recipients = append(k.recipients, recipient)
+		}
+
+		path, err := IdentityPath(cfg)
+		if err != nil {
+			return nil, err
+		}
+		identities, err := ReadIdentities(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, identity := range identities {
+			k.identities = append(k.identities, identity)
+		}
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown encryption method %q", cfg.Method)
+}
+
+// Encrypt returns plain encrypted to every recipient
+func (k *Keyring) Encrypt(plain []byte) ([]byte, error) {
+	if k == nil {
+		return plain, nil
+	}
+	if err := k.derive(); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	w, err := age.Encrypt(&b, k.recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plain); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Decrypt returns the plain content of data. Data that is not encrypted is
+// returned as it is.
+func (k *Keyring) Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if k == nil {
+		return nil, ErrEncrypted
+	}
+	if err := k.derive(); err != nil {
+		return nil, err
+	}
+	if len(k.identities) == 0 {
+		return nil, errors.New("content is encrypted and there is no key to decrypt it with, see ctrls key generate")
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(data), k.identities...)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, errors.New("content is encrypted for someone else, none of your keys can decrypt it")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// Recipients returns a digest of the recipients content is encrypted to,
+// which tells whether content encrypted earlier is readable by the same
+// people. It is empty for a nil Keyring.
+func (k *Keyring) Recipients() (string, error) {
+	if k == nil {
+		return "", nil
+	}
+	if err := k.derive(); err != nil {
+		return "", err
+	}
+
+	var keys []string
+	for _, recipient := range k.recipients {
+		keys = append(keys, fmt.Sprint(recipient))
+	}
+	sort.Strings(keys)
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// derive sets up the keys of the passphrase method
+func (k *Keyring) derive() error {
+	if k.passphrase == nil {
+		return nil
+	}
+
+	k.once.Do(func() {
+		passphrase, err := k.passphrase()
+		if err != nil {
+			k.err = err
+			return
+		}
+		identity, err := PassphraseIdentity(passphrase, k.salt)
+		if err != nil {
+			k.err = err
+			return
+		}
+		k.identities = []age.Identity{identity}
+		k.recipients = []age.Recipient{identity.Recipient()}
+	})
+	return k.err
+}
+
+// PassphraseIdentity derives the X25519 key of a passphrase with scrypt.
+// Keys are derived once per run rather than per file as age's own scrypt
+// recipient does, which keeps reading many versions fast.
+func PassphraseIdentity(passphrase, salt string) (*age.X25519Identity, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty passphrase")
+	}
+	key, err := scrypt.Key([]byte(passphrase), []byte("versionctrls encryption "+salt), 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	return age.ParseX25519Identity(bech32Encode("AGE-SECRET-KEY-", key))
+}
+
+// IsEncrypted reports whether data was encrypted by a Keyring
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(header))
+}
+
+// PlainSize returns the size of the plain content of encrypted data of the
+// given total size, from its first bytes. It reports false when prefix is
+// not the start of encrypted data or does not hold the whole header.
+func PlainSize(prefix []byte, total int64) (int64, bool) {
+	if !IsEncrypted(prefix) {
+		return 0, false
+	}
+	end := bytes.Index(prefix, []byte("\n--- "))
+	if end < 0 {
+		return 0, false
+	}
+	newline := bytes.IndexByte(prefix[end+1:], '\n')
+	if newline < 0 {
+		return 0, false
+	}
+
+	payload := total - int64(end+1+newline+1) - payloadOverhead
+	chunks := (payload + payloadChunk + payloadOverhead - 1) / (payloadChunk + payloadOverhead)
+	if payload < 0 || chunks == 0 {
+		return 0, false
+	}
+	return payload - chunks*payloadOverhead, true
+}
@@ -0,0 +1,133 @@
+package encryption
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+)
+
+// passphraseKeyring returns the keyring of the passphrase method
+func passphraseKeyring(t *testing.T, passphrase, salt string) *Keyring {
+	t.Helper()
+	k, err := Load(config.Encryption{Method: config.EncryptPassphrase, Salt: salt}, func() (string, error) {
+		return passphrase, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeyringRoundTrip(t *testing.T) {
+	alice, _ := age.GenerateX25519Identity()
+	bob, _ := age.GenerateX25519Identity()
+	recipients := &Keyring{
+		recipients: []age.Recipient{alice.Recipient(), bob.Recipient()},
+		identities: []age.Identity{bob},
+	}
+
+	for name, k := range map[string]*Keyring{
+		"recipients": recipients,
+		"passphrase": passphraseKeyring(t, "hunter2", "test"),
+	} {
+		plain := []byte("package main\n")
+		data, err := k.Encrypt(plain)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !IsEncrypted(data) || bytes.Contains(data, plain) {
+			t.Errorf("%s: content is not encrypted", name)
+		}
+		got, err := k.Decrypt(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("%s: decrypted %q, want %q", name, got, plain)
+		}
+
+		if _, err := (*Keyring)(nil).Decrypt(data); err != ErrEncrypted {
+			t.Errorf("%s: decrypting without a keyring: %v, want ErrEncrypted", name, err)
+		}
+	}
+
+	// Every recipient can read it, others cannot
+	data, _ := recipients.Encrypt([]byte("shared"))
+	if got, err := (&Keyring{identities: []age.Identity{alice}}).Decrypt(data); err != nil || string(got) != "shared" {
+		t.Errorf("the other recipient decrypted %q, %v", got, err)
+	}
+	eve, _ := age.GenerateX25519Identity()
+	if _, err := (&Keyring{identities: []age.Identity{eve}}).Decrypt(data); err == nil {
+		t.Error("decrypted content encrypted for someone else")
+	}
+	if _, err := passphraseKeyring(t, "wrong", "test").Decrypt(data); err == nil {
+		t.Error("decrypted with the wrong passphrase")
+	}
+
+	// Plain content passes through
+	if got, err := recipients.Decrypt([]byte("plain")); err != nil || string(got) != "plain" {
+		t.Errorf("Decrypt of plain content = %q, %v", got, err)
+	}
+}
+
+func TestPassphraseIdentityIsDeterministic(t *testing.T) {
+	a, err := PassphraseIdentity("hunter2", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := PassphraseIdentity("hunter2", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.String() != b.String() {
+		t.Error("the same passphrase and salt derive different keys")
+	}
+
+	for _, other := range [][2]string{{"hunter3", "test"}, {"hunter2", "other"}} {
+		c, err := PassphraseIdentity(other[0], other[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.String() == a.String() {
+			t.Errorf("passphrase %q with salt %q derives the same key", other[0], other[1])
+		}
+	}
+
+	// Content encrypted in one run is read in the next
+	data, _ := passphraseKeyring(t, "hunter2", "test").Encrypt([]byte("kept"))
+	if got, err := passphraseKeyring(t, "hunter2", "test").Decrypt(data); err != nil || string(got) != "kept" {
+		t.Errorf("a new keyring decrypted %q, %v", got, err)
+	}
+
+	if _, err := PassphraseIdentity("", "test"); err == nil {
+		t.Error("derived a key from an empty passphrase")
+	}
+}
+
+func TestPlainSize(t *testing.T) {
+	k := passphraseKeyring(t, "hunter2", "test")
+	content := make([]byte, 3*payloadChunk+1)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	for _, size := range []int{0, 1, payloadChunk - 1, payloadChunk, payloadChunk + 1, 2 * payloadChunk, 3*payloadChunk + 1} {
+		data, err := k.Encrypt(content[:size])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := PlainSize(data[:min(len(data), 256)], int64(len(data)))
+		if !ok || got != int64(size) {
+			t.Errorf("PlainSize of %d bytes = %d, %v", size, got, ok)
+		}
+	}
+
+	if _, ok := PlainSize([]byte("plain"), 5); ok {
+		t.Error("PlainSize of plain content reported a size")
+	}
+	data, _ := k.Encrypt([]byte("x"))
+	if _, ok := PlainSize(data[:len(header)+4], int64(len(data))); ok {
+		t.Error("PlainSize of a cut off header reported a size")
+	}
+}
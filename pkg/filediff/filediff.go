@@ -0,0 +1,275 @@
+package filediff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	gitdiff "github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// The surrogate range of UTF-16, whose code points are not valid runes
+const (
+	surrogateMin = 0xD800
+	surrogateMax = 0xDFFF
+)
+
+// Side is one of the two contents being compared. Label replaces the usual
+// a/ or b/ prefix in front of the file name in unified diffs.
+type Side struct {
+	Name    string
+	Label   string
+	Content []byte
+}
+
+// Unified writes a unified diff from one side to the other, in color if asked
+func Unified(w io.Writer, from, to Side, color bool) error {
+	encoder := diff.NewUnifiedEncoder(w, diff.DefaultContextLines)
+	if color {
+		encoder.SetColor(diff.NewColorConfig())
+	}
+	if from.Label != "" {
+		encoder.SetSrcPrefix(from.Label)
+	}
+	if to.Label != "" {
+		encoder.SetDstPrefix(to.Label)
+	}
+
+	return encoder.Encode(newPatch(from, to))
+}
+
+// Stat writes a diffstat summary of the change from one side to the other
+func Stat(w io.Writer, from, to Side, color bool) error {
+	name := to.Name
+	if name == "" {
+		name = from.Name
+	}
+
+	if isBinary(from.Content) || isBinary(to.Content) {
+		_, err := fmt.Fprintf(w, " %s | Bin %d -> %d bytes\n 1 file changed\n", name, len(from.Content), len(to.Content))
+		return err
+	}
+
+	added, deleted := 0, 0
+	for _, d := range gitdiff.Do(string(from.Content), string(to.Content)) {
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			added += countLines(d.Text)
+		case diffmatchpatch.DiffDelete:
+			deleted += countLines(d.Text)
+		}
+	}
+
+	plus, minus := strings.Repeat("+", added), strings.Repeat("-", deleted)
+	if color {
+		plus, minus = colorize(diff.New, plus), colorize(diff.Old, minus)
+	}
+
+	_, err := fmt.Fprintf(w, " %s | %d %s%s\n 1 file changed, %d insertion%s(+), %d deletion%s(-)\n",
+		name, added+deleted, plus, minus, added, plural(added), deleted, plural(deleted))
+	return err
+}
+
+// Words writes the change from one side to the other inline, marking removed
+// words as [-word-] and added words as {+word+}, or with colors if asked
+func Words(w io.Writer, from, to Side, color bool) error {
+	if isBinary(from.Content) || isBinary(to.Content) {
+		_, err := fmt.Fprintf(w, "Binary files %s and %s differ\n", from.Name, to.Name)
+		return err
+	}
+
+	var diffs []diffmatchpatch.Diff
+	src, dst, tokens, ok := wordsToRunes(string(from.Content), string(to.Content))
+	if ok {
+		for _, d := range diffmatchpatch.New().DiffMainRunes(src, dst, false) {
+			d.Text = runesToWords(d.Text, tokens)
+			diffs = append(diffs, d)
+		}
+	} else {
+		// More distinct words than runes to stand for them, so lines it is
+		diffs = gitdiff.Do(string(from.Content), string(to.Content))
+	}
+
+	var b strings.Builder
+	for _, d := range diffs {
+		text := d.Text
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			b.WriteString(text)
+		case diffmatchpatch.DiffDelete:
+			if color {
+				b.WriteString(colorize(diff.Old, text))
+			} else {
+				b.WriteString("[-" + text + "-]")
+			}
+		case diffmatchpatch.DiffInsert:
+			if color {
+				b.WriteString(colorize(diff.New, text))
+			} else {
+				b.WriteString("{+" + text + "+}")
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// wordsToRunes splits both texts into runs of whitespace and non-whitespace
+// and maps each distinct run to a rune so they can be diffed as units. The
+// runes skip the surrogate range, which does not survive the conversion to
+// a string. It reports false when there are more distinct runs than runes.
+func wordsToRunes(a, b string) ([]rune, []rune, map[rune]string, bool) {
+	tokens := map[rune]string{}
+	index := map[string]rune{}
+	next := rune(1)
+
+	encode := func(text string) ([]rune, bool) {
+		var runes []rune
+		for _, token := range splitWords(text) {
+			r, ok := index[token]
+			if !ok {
+				if next > utf8.MaxRune {
+					return nil, false
+				}
+				r = next
+				index[token] = r
+				tokens[r] = token
+				next++
+				if next == surrogateMin {
+					next = surrogateMax + 1
+				}
+			}
+			runes = append(runes, r)
+		}
+		return runes, true
+	}
+
+	src, ok := encode(a)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	dst, ok := encode(b)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	return src, dst, tokens, true
+}
+
+// runesToWords expands text produced by wordsToRunes back into words
+func runesToWords(text string, tokens map[rune]string) string {
+	var b strings.Builder
+	for _, r := range text {
+		b.WriteString(tokens[r])
+	}
+	return b.String()
+}
+
+// splitWords splits text into alternating runs of whitespace and other characters
+func splitWords(text string) []string {
+	var words []string
+	start := 0
+	for i, r := range text {
+		if i > start && isSpace(r) != isSpace(rune(text[start])) {
+			words = append(words, text[start:i])
+			start = i
+		}
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+func colorize(key diff.ColorKey, text string) string {
+	cc := diff.NewColorConfig()
+	return cc[key] + text + cc.Reset(key)
+}
+
+func countLines(text string) int {
+	n := strings.Count(text, "\n")
+	if !strings.HasSuffix(text, "\n") {
+		n++
+	}
+	return n
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// isBinary uses the same heuristic as git: a NUL byte in the first 8000 bytes
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// patch adapts a pair of contents to the go-git diff.Patch interface
+type patch struct {
+	from, to file
+	chunks   []diff.Chunk
+	binary   bool
+}
+
+type file struct {
+	path string
+	hash plumbing.Hash
+}
+
+type chunk struct {
+	content string
+	op      diff.Operation
+}
+
+func newPatch(from, to Side) *patch {
+	p := &patch{
+		from:   file{path: from.Name, hash: plumbing.ComputeHash(plumbing.BlobObject, from.Content)},
+		to:     file{path: to.Name, hash: plumbing.ComputeHash(plumbing.BlobObject, to.Content)},
+		binary: isBinary(from.Content) || isBinary(to.Content),
+	}
+
+	if p.binary {
+		return p
+	}
+
+	for _, d := range gitdiff.Do(string(from.Content), string(to.Content)) {
+		op := diff.Equal
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = diff.Add
+		case diffmatchpatch.DiffDelete:
+			op = diff.Delete
+		}
+		p.chunks = append(p.chunks, chunk{content: d.Text, op: op})
+	}
+
+	return p
+}
+
+func (p *patch) FilePatches() []diff.FilePatch { return []diff.FilePatch{p} }
+func (p *patch) Message() string               { return "" }
+func (p *patch) IsBinary() bool                { return p.binary }
+func (p *patch) Files() (diff.File, diff.File) { return p.from, p.to }
+func (p *patch) Chunks() []diff.Chunk          { return p.chunks }
+
+func (f file) Hash() plumbing.Hash     { return f.hash }
+func (f file) Mode() filemode.FileMode { return filemode.Regular }
+func (f file) Path() string            { return f.path }
+
+func (c chunk) Content() string      { return c.content }
+func (c chunk) Type() diff.Operation { return c.op }
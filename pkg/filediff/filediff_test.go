@@ -0,0 +1,64 @@
+package filediff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// words returns n distinct words, from first on, perLine to a line
+func words(first, n, perLine int) string {
+	var b strings.Builder
+	for i := first; i < first+n; i++ {
+		sep := " "
+		if (i-first+1)%perLine == 0 {
+			sep = "\n"
+		}
+		fmt.Fprintf(&b, "w%d%s", i, sep)
+	}
+	return b.String()
+}
+
+func TestWords(t *testing.T) {
+	var b strings.Builder
+	err := Words(&b, Side{Name: "a", Content: []byte("the quick brown fox\n")}, Side{Name: "b", Content: []byte("the slow brown fox\n")}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "the [-quick-]{+slow+} brown fox\n"; b.String() != want {
+		t.Errorf("Words = %q, want %q", b.String(), want)
+	}
+}
+
+func TestWordsPastSurrogates(t *testing.T) {
+	// Enough distinct words for their runes to reach the surrogate range
+	from := words(0, 60000, 1)
+	to := strings.Replace(from, "w59999\n", "changed\n", 1)
+
+	var b strings.Builder
+	if err := Words(&b, Side{Name: "a", Content: []byte(from)}, Side{Name: "b", Content: []byte(to)}, false); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	if strings.ContainsRune(got, '\uFFFD') {
+		t.Error("Words output has replacement characters")
+	}
+	if !strings.HasSuffix(got, "[-w59999-]{+changed+}\n") || !strings.HasPrefix(got, "w0\nw1\n") {
+		t.Errorf("Words output ends with %q", got[len(got)-200:])
+	}
+}
+
+func TestWordsFallsBackToLines(t *testing.T) {
+	// More distinct words than there are runes
+	from := words(0, 1200000, 10)
+	to := strings.Replace(from, "w1199999\n", "changed\n", 1)
+
+	var b strings.Builder
+	if err := Words(&b, Side{Name: "a", Content: []byte(from)}, Side{Name: "b", Content: []byte(to)}, false); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	if !strings.HasSuffix(got, "[-w1199990 w1199991 w1199992 w1199993 w1199994 w1199995 w1199996 w1199997 w1199998 w1199999\n-]{+w1199990 w1199991 w1199992 w1199993 w1199994 w1199995 w1199996 w1199997 w1199998 changed\n+}") || !strings.HasPrefix(got, "w0 w1 ") {
+		t.Errorf("Words output ends with %q", got[len(got)-200:])
+	}
+}
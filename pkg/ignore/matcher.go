@@ -0,0 +1,180 @@
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// File is the name of the ignore files read from every directory of a
+// project, in gitignore syntax
+const File = ".ctrlsignore"
+
+const gitignoreFile = ".gitignore"
+
+// Matcher tells which files of a project are never versioned. Patterns are
+// layered from the global excludes of git, .git/info/exclude and the
+// .gitignore files, then the presets, then the .ctrlsignore files, each
+// layer able to override the ones before with "!" patterns.
+type Matcher struct {
+	matcher gitignore.Matcher
+}
+
+// Load reads the ignore rules of the project at root with the given presets
+// turned on
+func Load(root string, presets []string) (*Matcher, error) {
+	patterns, err := globalPatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := readFile(filepath.Join(root, ".git", "info", "exclude"), nil)
+	if err != nil {
+		return nil, err
+	}
+	patterns = append(patterns, exclude...)
+
+	var presetPatterns []gitignore.Pattern
+	for _, name := range presets {
+		preset, ok := LookupPreset(name)
+		if !ok {
+			return nil, errors.New("unknown ignore preset " + name)
+		}
+		for _, p := range preset.Patterns {
+			presetPatterns = append(presetPatterns, gitignore.ParsePattern(p, nil))
+		}
+	}
+
+	gitignores, ctrlsignores, err := readTree(root, patterns, presetPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Matcher{matcher: gitignore.NewMatcher(concat(patterns, gitignores, presetPatterns, ctrlsignores))}, nil
+}
+
+// Match reports whether path, slash separated and relative to the project
+// root, is ignored
+func (m *Matcher) Match(path string, isDir bool) bool {
+	if m == nil || path == "" || path == "." {
+		return false
+	}
+	return m.matcher.Match(strings.Split(path, "/"), isDir)
+}
+
+// readTree reads the .gitignore and .ctrlsignore files of every directory
+// under root, skipping .git and the directories that are ignored already
+func readTree(root string, global, presets []gitignore.Pattern) ([]gitignore.Pattern, []gitignore.Pattern, error) {
+	var gitignores, ctrlsignores []gitignore.Pattern
+
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		var domain []string
+		if rel != "." {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			domain = strings.Split(filepath.ToSlash(rel), "/")
+
+			if gitignore.NewMatcher(concat(global, gitignores, presets, ctrlsignores)).Match(domain, true) {
+				return filepath.SkipDir
+			}
+		}
+
+		found, err := readFile(filepath.Join(path, gitignoreFile), domain)
+		if err != nil {
+			return err
+		}
+		gitignores = append(gitignores, found...)
+
+		found, err = readFile(filepath.Join(path, File), domain)
+		if err != nil {
+			return err
+		}
+		ctrlsignores = append(ctrlsignores, found...)
+		return nil
+	})
+
+	return gitignores, ctrlsignores, err
+}
+
+// concat joins layers of patterns in order of priority, last highest
+func concat(layers ...[]gitignore.Pattern) []gitignore.Pattern {
+	var patterns []gitignore.Pattern
+	for _, layer := range layers {
+		patterns = append(patterns, layer...)
+	}
+	return patterns
+}
+
+// globalPatterns reads the system and user excludes files of git, falling
+// back to $XDG_CONFIG_HOME/git/ignore like git does when core.excludesfile
+// is not set
+func globalPatterns() ([]gitignore.Pattern, error) {
+	fs := osfs.New("/")
+
+	patterns, err := gitignore.LoadSystemPatterns(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	global, err := gitignore.LoadGlobalPatterns(fs)
+	if err != nil {
+		return nil, err
+	}
+	if global == nil {
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		if dir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return patterns, nil
+			}
+			dir = filepath.Join(home, ".config")
+		}
+		global, err = readFile(filepath.Join(dir, "git", "ignore"), nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(patterns, global...), nil
+}
+
+// readFile parses the ignore file at path, whose patterns apply below
+// domain. A missing file has no patterns.
+func readFile(path string, domain []string) ([]gitignore.Pattern, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []gitignore.Pattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,68 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		filepath.Join(home, ".config", "git", "ignore"): "*.global\n",
+		filepath.Join(root, ".git", "info", "exclude"):  "# local only\n*.local\n",
+		filepath.Join(root, ".gitignore"):               "*.gen\nsecret.txt\n",
+		filepath.Join(root, "sub", ".gitignore"):        "data/\n",
+		filepath.Join(root, File):                       "!keep.gen\n*.bak\n",
+		filepath.Join(root, "sub", File):                "!.env\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := Load(root, []string{"node", "env"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"main.go", false, false},
+		{"a.global", false, true},
+		{"dir/a.global", false, true},
+		{"a.local", false, true},
+		{"a.gen", false, true},
+		{"secret.txt", false, true},
+		{"sub/data", true, true},
+		{"sub/data/x.csv", false, true},
+		{"data", true, false},
+		{"node_modules", true, true},
+		{"app.log", false, true},
+		{".env", false, true},
+		{".env.example", false, false},
+		{"a.bak", false, true},
+		// .ctrlsignore wins over .gitignore and the presets
+		{"keep.gen", false, false},
+		{"sub/.env", false, false},
+		{"", false, false},
+	} {
+		if got := m.Match(c.path, c.isDir); got != c.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", c.path, c.isDir, got, c.want)
+		}
+	}
+
+	if _, err := Load(root, []string{"nope"}); err == nil {
+		t.Error("loaded an unknown preset")
+	}
+}
@@ -0,0 +1,52 @@
+package ignore
+
+// Preset is a named set of gitignore patterns for a common kind of project
+type Preset struct {
+	Name        string
+	Description string
+	Patterns    []string
+}
+
+// Presets are the ignore presets projects can turn on in their configuration
+var Presets = []Preset{
+	{
+		Name:        "node",
+		Description: "Node.js dependencies and build output",
+		Patterns:    []string{"node_modules/", ".next/", ".nuxt/", "dist/", "coverage/", "*.log"},
+	},
+	{
+		Name:        "go",
+		Description: "Go binaries, vendored modules and test output",
+		Patterns:    []string{"vendor/", "bin/", "*.test", "*.out"},
+	},
+	{
+		Name:        "python",
+		Description: "Python caches and virtual environments",
+		Patterns:    []string{"__pycache__/", "*.py[cod]", ".venv/", "venv/", ".pytest_cache/", "*.egg-info/"},
+	},
+	{
+		Name:        "build",
+		Description: "Common build directories",
+		Patterns:    []string{"build/", "out/", "target/", "tmp/"},
+	},
+	{
+		Name:        "editors",
+		Description: "Editor and OS files",
+		Patterns:    []string{".idea/", ".vscode/", "*.swp", "*~", ".DS_Store", "Thumbs.db"},
+	},
+	{
+		Name:        "env",
+		Description: "Local environment files with secrets",
+		Patterns:    []string{".env", ".env.*", "!.env.example"},
+	},
+}
+
+// LookupPreset returns the preset called name
+func LookupPreset(name string) (Preset, bool) {
+	for _, preset := range Presets {
+		if preset.Name == name {
+			return preset, true
+		}
+	}
+	return Preset{}, false
+}
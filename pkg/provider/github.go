@@ -0,0 +1,239 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitHub talks to the GitHub REST API, on github.com or GitHub Enterprise
+type GitHub struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewGitHub returns a GitHub client for the API at baseURL
+func NewGitHub(baseURL, token string, client *http.Client) *GitHub {
+	return &GitHub{baseURL: strings.TrimSuffix(baseURL, "/"), token: token, client: client}
+}
+
+// Name returns "github"
+func (g *GitHub) Name() string {
+	return "github"
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type githubRepo struct {
+	Name     string     `json:"name"`
+	Private  bool       `json:"private"`
+	CloneURL string     `json:"clone_url"`
+	HTMLURL  string     `json:"html_url"`
+	Owner    githubUser `json:"owner"`
+}
+
+func (r githubRepo) repository() Repository {
+	return Repository{
+		Owner:    r.Owner.Login,
+		Name:     r.Name,
+		Private:  r.Private,
+		CloneURL: r.CloneURL,
+		WebURL:   r.HTMLURL,
+	}
+}
+
+// CurrentUser returns the account the API key belongs to
+func (g *GitHub) CurrentUser(ctx context.Context) (User, error) {
+	var user githubUser
+	if _, err := g.do(ctx, http.MethodGet, "/user", nil, &user); err != nil {
+		return User{}, err
+	}
+	return User{Login: user.Login, Name: user.Name}, nil
+}
+
+// githubPushScope is the classic token scope needed to create private
+// repositories and push to them
+const githubPushScope = "repo"
+
+// VerifyToken reads the scopes and the expiry date GitHub sends along with
+// the current user
+func (g *GitHub) VerifyToken(ctx context.Context) (TokenInfo, error) {
+	var user githubUser
+	resp, err := g.do(ctx, http.MethodGet, "/user", nil, &user)
+	if isStatus(err, http.StatusUnauthorized) {
+		return TokenInfo{}, ErrInvalidToken
+	}
+	if err != nil {
+		return TokenInfo{}, err
+	}
+
+	info := TokenInfo{User: User{Login: user.Login, Name: user.Name}}
+
+	// Only classic tokens have scopes, the header is missing altogether for
+	// fine-grained ones
+	if values, ok := resp.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]; ok {
+		info.Scopes = []string{}
+		for _, value := range values {
+			for _, scope := range strings.Split(value, ",") {
+				if scope = strings.TrimSpace(scope); scope != "" {
+					info.Scopes = append(info.Scopes, scope)
+				}
+			}
+		}
+		if !containsScope(info.Scopes, githubPushScope) {
+			info.Missing = []string{githubPushScope}
+		}
+	}
+
+	if value := resp.Header.Get("GitHub-Authentication-Token-Expiration"); value != "" {
+		for _, layout := range []string{"2006-01-02 15:04:05 MST", "2006-01-02 15:04:05 -0700"} {
+			if expires, err := time.Parse(layout, value); err == nil {
+				info.Expires = expires
+				break
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// Repository looks up owner/name
+func (g *GitHub) Repository(ctx context.Context, owner, name string) (Repository, error) {
+	var repo githubRepo
+	path := "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(name)
+	_, err := g.do(ctx, http.MethodGet, path, nil, &repo)
+	if isStatus(err, http.StatusNotFound) {
+		return Repository{}, ErrNotFound
+	}
+	if err != nil {
+		return Repository{}, err
+	}
+	return repo.repository(), nil
+}
+
+// CreateRepository creates a private repository with a README so that it can
+// be cloned right away
+func (g *GitHub) CreateRepository(ctx context.Context, owner, name string) (Repository, error) {
+	path := "/user/repos"
+	if owner != "" {
+		user, err := g.CurrentUser(ctx)
+		if err != nil {
+			return Repository{}, err
+		}
+		if !strings.EqualFold(owner, user.Login) {
+			path = "/orgs/" + url.PathEscape(owner) + "/repos"
+		}
+	}
+
+	body := map[string]interface{}{
+		"name":        name,
+		"private":     true,
+		"auto_init":   true,
+		"description": "Versionctrls integration repository",
+	}
+
+	var repo githubRepo
+	_, err := g.do(ctx, http.MethodPost, path, body, &repo)
+	if isStatus(err, http.StatusUnprocessableEntity) && strings.Contains(err.Error(), "already exists") {
+		return Repository{}, ErrAlreadyExists
+	}
+	if isStatus(err, http.StatusNotFound) && path != "/user/repos" {
+		return Repository{}, fmt.Errorf("%s is not an organization you can create repositories in", owner)
+	}
+	if err != nil {
+		return Repository{}, err
+	}
+	return repo.repository(), nil
+}
+
+// do sends a request to the API and decodes the JSON answer into out
+func (g *GitHub) do(ctx context.Context, method, path string, in, out interface{}) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", "versionctrls-cli")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, githubError(resp.StatusCode, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp, fmt.Errorf("could not decode answer from %s: %w", path, err)
+		}
+	}
+	return resp, nil
+}
+
+// githubError turns an error answer into an APIError, including the
+// validation details GitHub sends for rejected requests
+func githubError(status int, data []byte) error {
+	var answer struct {
+		Message string `json:"message"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	json.Unmarshal(data, &answer)
+
+	message := answer.Message
+	for _, detail := range answer.Errors {
+		if detail.Message != "" {
+			message += ": " + detail.Message
+		}
+	}
+	return &APIError{StatusCode: status, Message: message}
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func isStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
@@ -0,0 +1,201 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/provider"
+	"github.com/renatonmag/versionctrls-cli/pkg/provider/providertest"
+)
+
+const testToken = "ghp_test"
+
+func newServer(t *testing.T, orgs ...string) *providertest.Server {
+	t.Helper()
+	srv := providertest.NewServer(testToken, "alice", t.TempDir(), orgs...)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newClient(srv *providertest.Server, token string) *provider.GitHub {
+	return provider.NewGitHub(srv.URL, token, srv.Client())
+}
+
+func TestCreateRepository(t *testing.T) {
+	srv := newServer(t, "acme")
+	client := newClient(srv, testToken)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		owner, name, wantOwner string
+	}{
+		{"", "project-versionctrls", "alice"},
+		{"alice", "other-versionctrls", "alice"},
+		{"ALICE", "third-versionctrls", "alice"},
+		{"acme", "project-versionctrls", "acme"},
+	} {
+		repo, err := client.CreateRepository(ctx, tc.owner, tc.name)
+		if err != nil {
+			t.Fatalf("CreateRepository(%q, %q): %v", tc.owner, tc.name, err)
+		}
+		if repo.Owner != tc.wantOwner || repo.Name != tc.name {
+			t.Errorf("CreateRepository(%q, %q) made %s/%s, want %s/%s", tc.owner, tc.name, repo.Owner, repo.Name, tc.wantOwner, tc.name)
+		}
+		if !repo.Private {
+			t.Errorf("%s/%s is not private", repo.Owner, repo.Name)
+		}
+		if _, err := os.Stat(repo.CloneURL); err != nil {
+			t.Errorf("clone URL of %s/%s: %v", repo.Owner, repo.Name, err)
+		}
+
+		found, err := client.Repository(ctx, repo.Owner, repo.Name)
+		if err != nil || found.CloneURL != repo.CloneURL {
+			t.Errorf("Repository(%s, %s) = %+v, %v", repo.Owner, repo.Name, found, err)
+		}
+	}
+
+	if got := len(srv.Repositories()); got != 4 {
+		t.Errorf("server has %d repositories, want 4", got)
+	}
+}
+
+func TestCreateRepositoryCollision(t *testing.T) {
+	srv := newServer(t)
+	client := newClient(srv, testToken)
+	ctx := context.Background()
+
+	if _, err := srv.AddRepository("alice", "project-versionctrls"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"project-versionctrls", "Project-Versionctrls"} {
+		_, err := client.CreateRepository(ctx, "", name)
+		if !errors.Is(err, provider.ErrAlreadyExists) {
+			t.Errorf("CreateRepository(%q) = %v, want ErrAlreadyExists", name, err)
+		}
+	}
+}
+
+func TestCreateRepositoryErrors(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+
+	_, err := newClient(srv, "wrong").CreateRepository(ctx, "", "project-versionctrls")
+	var apiErr *provider.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("with a wrong token: %v, want a 401 APIError", err)
+	}
+
+	client := newClient(srv, testToken)
+	_, err = client.CreateRepository(ctx, "nobody", "project-versionctrls")
+	if err == nil {
+		t.Error("creating in an organization that does not exist succeeded")
+	}
+
+	srv.FailNext(http.StatusInternalServerError, "Server Error")
+	_, err = client.CreateRepository(ctx, "", "project-versionctrls")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("with the API failing: %v, want a 500 APIError", err)
+	}
+
+	_, err = client.Repository(ctx, "alice", "missing")
+	if !errors.Is(err, provider.ErrNotFound) {
+		t.Errorf("Repository of a missing repository = %v, want ErrNotFound", err)
+	}
+
+	if len(srv.Repositories()) != 0 {
+		t.Errorf("failed requests created %v", srv.Repositories())
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("valid", func(t *testing.T) {
+		srv := newServer(t)
+		info, err := newClient(srv, testToken).VerifyToken(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.User.Login != "alice" {
+			t.Errorf("login = %q, want alice", info.User.Login)
+		}
+		if len(info.Scopes) != 1 || info.Scopes[0] != "repo" || len(info.Missing) != 0 {
+			t.Errorf("scopes = %v, missing = %v", info.Scopes, info.Missing)
+		}
+		if !info.Expires.IsZero() {
+			t.Errorf("expires = %v, want never", info.Expires)
+		}
+	})
+
+	t.Run("missing push scope", func(t *testing.T) {
+		srv := newServer(t)
+		srv.SetScopes([]string{"read:user", "gist"})
+		info, err := newClient(srv, testToken).VerifyToken(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(info.Missing) != 1 || info.Missing[0] != "repo" {
+			t.Errorf("missing = %v, want [repo]", info.Missing)
+		}
+	})
+
+	t.Run("fine-grained", func(t *testing.T) {
+		srv := newServer(t)
+		srv.SetScopes(nil)
+		info, err := newClient(srv, testToken).VerifyToken(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Scopes != nil || info.Missing != nil {
+			t.Errorf("scopes = %v, missing = %v, want nil for a fine-grained key", info.Scopes, info.Missing)
+		}
+	})
+
+	t.Run("expiring", func(t *testing.T) {
+		srv := newServer(t)
+		srv.SetExpiry(now.Add(48 * time.Hour))
+		info, err := newClient(srv, testToken).VerifyToken(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ExpiresWithin(7*24*time.Hour, now) {
+			t.Errorf("expires = %v, want within a week", info.Expires)
+		}
+		if info.ExpiresWithin(24*time.Hour, now) {
+			t.Errorf("expires = %v, want after a day", info.Expires)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		srv := newServer(t)
+		srv.SetExpiry(now.Add(-time.Hour))
+		_, err := newClient(srv, testToken).VerifyToken(ctx)
+		if !errors.Is(err, provider.ErrInvalidToken) {
+			t.Errorf("err = %v, want ErrInvalidToken", err)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		srv := newServer(t)
+		_, err := newClient(srv, "wrong").VerifyToken(ctx)
+		if !errors.Is(err, provider.ErrInvalidToken) {
+			t.Errorf("err = %v, want ErrInvalidToken", err)
+		}
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		srv := newServer(t)
+		srv.FailNext(http.StatusServiceUnavailable, "Service Unavailable")
+		_, err := newClient(srv, testToken).VerifyToken(ctx)
+		var apiErr *provider.APIError
+		if errors.Is(err, provider.ErrInvalidToken) || !errors.As(err, &apiErr) {
+			t.Errorf("err = %v, want a 503 APIError", err)
+		}
+	})
+}
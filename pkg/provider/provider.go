@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// APIURLEnv overrides the API address of a provider, for self-hosted
+// instances with unusual layouts and for tests
+const APIURLEnv = "VERSIONCTRLS_API_URL"
+
+// ErrNotFound is returned for repositories that do not exist or that the
+// API key cannot see
+var ErrNotFound = errors.New("repository not found")
+
+// ErrAlreadyExists is returned when creating a repository whose name is taken
+var ErrAlreadyExists = errors.New("repository already exists")
+
+// ErrInvalidToken is returned when the provider rejects the API key
+var ErrInvalidToken = errors.New("the API key was rejected, it may be mistyped, revoked or expired")
+
+// ErrUnsupported is returned by New for providers that have no API client yet
+var ErrUnsupported = errors.New("provider is not supported yet")
+
+// Provider talks to the API of a git hosting service
+type Provider interface {
+	// Name returns the name of the provider as accepted by New
+	Name() string
+	// CurrentUser returns the account the API key belongs to
+	CurrentUser(ctx context.Context) (User, error)
+	// VerifyToken checks the API key against the current user endpoint and
+	// reports what it allows
+	VerifyToken(ctx context.Context) (TokenInfo, error)
+	// Repository looks up owner/name
+	Repository(ctx context.Context, owner, name string) (Repository, error)
+	// CreateRepository creates a private repository with a first commit.
+	// An empty owner, or the login of the current user, creates it in the
+	// user's own account, any other owner in that organization.
+	CreateRepository(ctx context.Context, owner, name string) (Repository, error)
+}
+
+// User is an account on a provider
+type User struct {
+	Login string
+	Name  string
+}
+
+// TokenInfo describes an API key as the provider sees it
+type TokenInfo struct {
+	User User
+	// Scopes is nil when the provider does not report them, as for GitHub
+	// fine-grained tokens whose permissions are set per repository
+	Scopes []string
+	// Missing lists the scopes needed to create and push to the integration
+	// repository that the key lacks
+	Missing []string
+	// Expires is zero for keys that never expire or when it is unknown
+	Expires time.Time
+}
+
+// ExpiresWithin reports whether the key expires less than d after now
+func (t TokenInfo) ExpiresWithin(d time.Duration, now time.Time) bool {
+	return !t.Expires.IsZero() && t.Expires.Before(now.Add(d))
+}
+
+// Repository is a repository on a provider
+type Repository struct {
+	Owner    string
+	Name     string
+	Private  bool
+	CloneURL string
+	WebURL   string
+}
+
+// APIError is an unexpected answer from a provider API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("API request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+// New returns the provider called name for the given host, authenticating
+// with token
+func New(name, host, token string) (Provider, error) {
+	switch name {
+	case "github":
+		return NewGitHub(apiURL(host, "github.com", "https://api.github.com", "/api/v3"), token, http.DefaultClient), nil
+	case "gitlab", "gitea":
+		return nil, fmt.Errorf("%s: %w", name, ErrUnsupported)
+	default:
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+}
+
+// Detect returns the name of the provider that serves host, or "" when it
+// cannot tell
+func Detect(host string) string {
+	switch {
+	case host == "github.com":
+		return "github"
+	case host == "gitlab.com" || strings.HasPrefix(host, "gitlab."):
+		return "gitlab"
+	case host == "gitea.com" || strings.HasPrefix(host, "gitea."):
+		return "gitea"
+	}
+	return ""
+}
+
+// apiURL returns the API address for host: public for the public instance
+// at publicHost, and path on the host itself for self-hosted instances
+func apiURL(host, publicHost, public, path string) string {
+	if url := os.Getenv(APIURLEnv); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	if host == "" || host == publicHost {
+		return public
+	}
+	return "https://" + host + path
+}
@@ -0,0 +1,243 @@
+package providertest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/provider"
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+)
+
+// Server stands in for the GitHub REST API. Repositories it creates are bare
+// repositories under a local directory and their clone URLs are plain paths,
+// so everything from creating the integration repository to pushing
+// snapshots works offline. Point provider.APIURLEnv at URL to use it.
+type Server struct {
+	*httptest.Server
+
+	token string
+	login string
+	orgs  map[string]bool
+	dir   string
+
+	mu      sync.Mutex
+	repos   map[string]provider.Repository
+	scopes  []string
+	expires time.Time
+	failure *failure
+}
+
+// failure is the answer the next request gets instead of its own
+type failure struct {
+	status  int
+	message string
+}
+
+// NewServer starts a server that accepts token for the account login, which
+// may create repositories in its own account and in orgs. Repositories are
+// kept under dir.
+func NewServer(token, login, dir string, orgs ...string) *Server {
+	s := &Server{
+		token:  token,
+		login:  login,
+		orgs:   map[string]bool{},
+		dir:    dir,
+		repos:  map[string]provider.Repository{},
+		scopes: []string{"repo"},
+	}
+	for _, org := range orgs {
+		s.orgs[org] = true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /user", s.authorized(s.getUser))
+	mux.HandleFunc("GET /repos/{owner}/{name}", s.authorized(s.getRepo))
+	mux.HandleFunc("POST /user/repos", s.authorized(s.createRepo))
+	mux.HandleFunc("POST /orgs/{org}/repos", s.authorized(s.createRepo))
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// SetScopes sets the scopes reported for the token, "repo" by default. Nil
+// reports no scopes at all, like GitHub does for fine-grained tokens.
+func (s *Server) SetScopes(scopes []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scopes = scopes
+}
+
+// SetExpiry makes the token expire at t, the zero time meaning never.
+// Requests with an expired token are rejected as bad credentials.
+func (s *Server) SetExpiry(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expires = t
+}
+
+// FailNext makes the next request fail with status and message, as when
+// the API is down or rate limited
+func (s *Server) FailNext(status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failure = &failure{status: status, message: message}
+}
+
+// AddRepository creates owner/name as if it had been made on the website
+func (s *Server) AddRepository(owner, name string) (provider.Repository, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addRepository(owner, name)
+}
+
+// Repositories returns every repository on the server, sorted by owner and name
+func (s *Server) Repositories() []provider.Repository {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repos := make([]provider.Repository, 0, len(s.repos))
+	for _, repo := range s.repos {
+		repos = append(repos, repo)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Owner+"/"+repos[i].Name < repos[j].Owner+"/"+repos[j].Name
+	})
+	return repos
+}
+
+func (s *Server) addRepository(owner, name string) (provider.Repository, error) {
+	path := filepath.Join(s.dir, owner, name+".git")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return provider.Repository{}, err
+	}
+	if err := repository.InitBareIntegration(path); err != nil {
+		return provider.Repository{}, err
+	}
+
+	repo := provider.Repository{
+		Owner:    owner,
+		Name:     name,
+		Private:  true,
+		CloneURL: path,
+		WebURL:   s.URL + "/" + owner + "/" + name,
+	}
+	s.repos[strings.ToLower(owner+"/"+name)] = repo
+	return repo, nil
+}
+
+// authorized rejects requests that do not carry the server's token, or
+// carry it after it expired, and answers with the failure set by FailNext
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		fail, expires := s.failure, s.expires
+		s.failure = nil
+		s.mu.Unlock()
+
+		if fail != nil {
+			writeJSON(w, fail.status, map[string]string{"message": fail.message})
+			return
+		}
+
+		auth := r.Header.Get("Authorization")
+		if auth != "Bearer "+s.token && auth != "token "+s.token {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Bad credentials"})
+			return
+		}
+		if !expires.IsZero() && time.Now().After(expires) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Bad credentials"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	if s.scopes != nil {
+		w.Header().Set("X-OAuth-Scopes", strings.Join(s.scopes, ", "))
+	}
+	if !s.expires.IsZero() {
+		w.Header().Set("GitHub-Authentication-Token-Expiration", s.expires.UTC().Format("2006-01-02 15:04:05 MST"))
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]string{"login": s.login, "name": s.login})
+}
+
+func (s *Server) getRepo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	repo, ok := s.repos[strings.ToLower(r.PathValue("owner")+"/"+r.PathValue("name"))]
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, repoJSON(repo))
+}
+
+func (s *Server) createRepo(w http.ResponseWriter, r *http.Request) {
+	owner := s.login
+	if org := r.PathValue("org"); org != "" {
+		if !s.orgs[org] {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		owner = org
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"message": "Validation Failed"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.repos[strings.ToLower(owner+"/"+body.Name)]; ok {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"message": "Repository creation failed.",
+			"errors":  []map[string]string{{"message": "name already exists on this account"}},
+		})
+		return
+	}
+
+	repo, err := s.addRepository(owner, body.Name)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"message": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, repoJSON(repo))
+}
+
+func repoJSON(repo provider.Repository) map[string]interface{} {
+	return map[string]interface{}{
+		"name":      repo.Name,
+		"full_name": repo.Owner + "/" + repo.Name,
+		"private":   repo.Private,
+		"clone_url": repo.CloneURL,
+		"html_url":  repo.WebURL,
+		"owner":     map[string]string{"login": repo.Owner},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -2,15 +2,13 @@ package repository
 
 import (
 	"fmt"
-	"log"
 )
 
 func (r Repository) AddSubmodule(url, path string) error {
 	fmt.Println("Adding submodule...")
 	output, err := r.RunGitCommand("submodule", "add", url, path)
 	if err != nil {
-		log.Fatalf("Error: %v\nOutput: %s", err, output)
-		return err
+		return fmt.Errorf("%w\nOutput: %s", err, output)
 	} else {
 		fmt.Println("Submodules added successfully.")
 	}
@@ -18,8 +16,7 @@ func (r Repository) AddSubmodule(url, path string) error {
 	// Initialize submodules
 	fmt.Println("Initializing submodules...")
 	if output, err := r.RunGitCommand("submodule", "init"); err != nil {
-		log.Fatalf("Error: %v\nOutput: %s", err, output)
-		return err
+		return fmt.Errorf("%w\nOutput: %s", err, output)
 	} else {
 		fmt.Println("Submodules initialized successfully.")
 	}
@@ -27,8 +24,7 @@ func (r Repository) AddSubmodule(url, path string) error {
 	// Update submodules
 	fmt.Println("Updating submodules...")
 	if output, err := r.RunGitCommand("submodule", "update"); err != nil {
-		log.Fatalf("Error: %v\nOutput: %s", err, output)
-		return err
+		return fmt.Errorf("%w\nOutput: %s", err, output)
 	} else {
 		fmt.Println("Submodules updated successfully.")
 	}
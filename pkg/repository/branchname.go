@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	// maxBranchNameLength keeps the loose ref file name well under the 255
+	// byte limit of common filesystems
+	maxBranchNameLength = 200
+	// hashedMarker separates the readable prefix of an over-long branch name
+	// from the hash of its path. EncodeBranchName never writes "%h" otherwise
+	// since every other "%" starts an uppercase hex escape.
+	hashedMarker = "%h"
+)
+
+// FileBranchName returns the name of the branch that holds the versions of file
+func FileBranchName(file string) string {
+	return EncodeBranchName(filepath.ToSlash(file))
+}
+
+// legacyBranchName returns the name versions of file were kept under before
+// names were escaped, when path separators simply became "-"
+func legacyBranchName(file string) string {
+	return strings.ReplaceAll(filepath.ToSlash(file), "/", "-")
+}
+
+// EncodeBranchName turns a slash separated path into a valid branch name that
+// DecodeBranchName maps back to the same path. Path separators become "-",
+// and "-", "%" and any byte that is not safe in a ref name become %XX escapes,
+// so a/b-c.go and a-b/c.go get different names. Paths whose encoding would be
+// too long keep a readable prefix followed by a hash of the full path.
+func EncodeBranchName(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '/':
+			b.WriteByte('-')
+		case mustEscape(path, i):
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	name := b.String()
+	if len(name) <= maxBranchNameLength && ValidBranchName(name) {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(path))
+	prefix := name
+	if limit := maxBranchNameLength - len(hashedMarker) - 2*len(sum); len(prefix) > limit {
+		prefix = prefix[:limit]
+		// Never cut an escape in half
+		if i := strings.LastIndexByte(prefix, '%'); i >= 0 && i > len(prefix)-3 {
+			prefix = prefix[:i]
+		}
+	}
+	// Nor leave a dot next to the marker or a dash at the start
+	prefix = strings.TrimLeft(strings.TrimRight(prefix, "."), "-")
+
+	return prefix + hashedMarker + hex.EncodeToString(sum[:])
+}
+
+// DecodeBranchName returns the path encoded in a branch name. It reports
+// false for names that are not encodings, including hashed names, whose path
+// has to be read from the branch itself.
+func DecodeBranchName(name string) (string, bool) {
+	if strings.Contains(name, hashedMarker) {
+		return "", false
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch c {
+		case '-':
+			b.WriteByte('/')
+		case '%':
+			if i+2 >= len(name) {
+				return "", false
+			}
+			v, err := strconv.ParseUint(name[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", false
+			}
+			b.WriteByte(byte(v))
+			i += 2
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	path := b.String()
+	if EncodeBranchName(path) != name {
+		return "", false
+	}
+	return path, true
+}
+
+// IsHashedBranchName reports whether name was shortened with a hash by
+// EncodeBranchName
+func IsHashedBranchName(name string) bool {
+	return strings.Contains(name, hashedMarker)
+}
+
+// mustEscape reports whether the byte at path[i] has to be written as %XX
+func mustEscape(path string, i int) bool {
+	c := path[i]
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return false
+	case c == '_' || c == '+' || c == ',' || c == '=':
+		return false
+	case c == '.':
+		// Dots may not start a name, follow a separator or another dot,
+		// or end the name or a ".lock" suffix
+		if i == 0 || path[i-1] == '/' || path[i-1] == '.' || i == len(path)-1 {
+			return true
+		}
+		return strings.HasSuffix(path, ".lock") && i == len(path)-len(".lock")
+	default:
+		// Separators handled by the caller, "-" and "%" because the encoding
+		// uses them, and everything else git rejects or may mangle
+		return true
+	}
+}
+
+// ValidBranchName reports whether name is a valid single component branch
+// name according to the rules of git check-ref-format
+func ValidBranchName(name string) bool {
+	if name == "" || name == "@" {
+		return false
+	}
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "-") {
+		return false
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock") {
+		return false
+	}
+	if strings.Contains(name, "..") || strings.Contains(name, "@{") {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < 0x20 || c == 0x7f {
+			return false
+		}
+		switch c {
+		case ' ', '~', '^', ':', '?', '*', '[', '\\', '/':
+			return false
+		}
+	}
+
+	return true
+}
+
+// BranchPath returns the path of the file whose versions are kept on
+// branchName, reading it from the branch history when the name is hashed
+func (r Repository) BranchPath(branchName string) (string, error) {
+	if path, ok := DecodeBranchName(branchName); ok {
+		return path, nil
+	}
+	if r.repo == nil {
+		return "", errors.New("no repository opened")
+	}
+
+	return r.RefPath(plumbing.NewBranchReferenceName(branchName))
+}
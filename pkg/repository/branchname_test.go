@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var trickyPaths = []string{
+	"main.go",
+	"a/b-c.go",
+	"a-b/c.go",
+	"..",
+	"a/../b",
+	"../outside",
+	".hidden",
+	"dir/.hidden",
+	"file.lock",
+	"dir/ref.lock",
+	"trailing.",
+	"a@{1}",
+	"@",
+	"100%",
+	"%41",
+	"%h",
+	"with space.txt",
+	"tab\tname",
+	"ünïcödé/文件.txt",
+	"emoji-😀.md",
+	"trailing/",
+	"/leading",
+	"a//b",
+	"-dash",
+	"star*?[x]~^:\\",
+	strings.Repeat("long/", 100) + "file.go",
+	strings.Repeat("%", 150),
+	"",
+}
+
+func TestEncodeBranchName(t *testing.T) {
+	for _, path := range trickyPaths {
+		name := EncodeBranchName(path)
+		if !ValidBranchName(name) {
+			t.Errorf("EncodeBranchName(%q) = %q, which is not a valid branch name", path, name)
+		}
+		if len(name) > maxBranchNameLength {
+			t.Errorf("EncodeBranchName(%q) is %d bytes long", path, len(name))
+		}
+		if IsHashedBranchName(name) {
+			continue
+		}
+		decoded, ok := DecodeBranchName(name)
+		if !ok || decoded != path {
+			t.Errorf("DecodeBranchName(%q) = %q, %v, want %q", name, decoded, ok, path)
+		}
+	}
+}
+
+func TestEncodeBranchNameDistinct(t *testing.T) {
+	seen := map[string]string{}
+	for _, path := range trickyPaths {
+		name := EncodeBranchName(path)
+		if other, ok := seen[name]; ok {
+			t.Errorf("%q and %q both encode to %q", path, other, name)
+		}
+		seen[name] = path
+	}
+}
+
+func FuzzEncodeBranchName(f *testing.F) {
+	for _, path := range trickyPaths {
+		f.Add(path)
+	}
+
+	f.Fuzz(func(t *testing.T, path string) {
+		name := EncodeBranchName(path)
+		if !ValidBranchName(name) {
+			t.Fatalf("EncodeBranchName(%q) = %q, which is not a valid branch name", path, name)
+		}
+		if IsHashedBranchName(name) {
+			return
+		}
+		decoded, ok := DecodeBranchName(name)
+		if !ok || decoded != path {
+			t.Fatalf("DecodeBranchName(%q) = %q, %v, want %q", name, decoded, ok, path)
+		}
+	})
+}
+
+func TestLegacyBranchIsRenamed(t *testing.T) {
+	testUser(t)
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Repository{repo: repo}
+
+	// A version saved when separators simply became dashes
+	file := "src/my-file.go"
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", "my-file.go"), []byte("package src\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	legacy := plumbing.NewBranchReferenceName("src-my-file.go")
+	if _, err := r.SnapshotFile(file, legacy, "Version 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another file with the same legacy name is left alone
+	other, err := r.FileRefName("src-my/file.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Reference(other, false); err != plumbing.ErrReferenceNotFound {
+		t.Errorf("%s exists for a file that was never saved: %v", other, err)
+	}
+
+	save(t, r, dir, file, "package src\n\nfunc F() {}\n")
+
+	if _, err := repo.Reference(legacy, false); err != plumbing.ErrReferenceNotFound {
+		t.Errorf("legacy branch %s is still there: %v", legacy, err)
+	}
+	versions, err := r.FileVersions(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("got %d versions of %s, want the legacy one and the new one", len(versions), file)
+	}
+}
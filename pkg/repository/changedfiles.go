@@ -6,7 +6,8 @@ import (
 	"github.com/go-git/go-git/v5"
 )
 
-// GetChangedFiles returns a list of changed files in the repository
+// GetChangedFiles returns a list of changed files in the repository, leaving
+// out ignored files
 func (r Repository) GetChangedFiles() ([]string, error) {
 	var changedFiles []string
 	if r.repo == nil {
@@ -24,7 +25,7 @@ func (r Repository) GetChangedFiles() ([]string, error) {
 	}
 
 	for file, fileStatus := range status {
-		if fileStatus.Worktree != git.Unmodified {
+		if fileStatus.Worktree != git.Unmodified && !r.Ignored(file) {
 			changedFiles = append(changedFiles, file)
 		}
 	}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func TestChangedFilesSkipTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Repository{repo: repo}
+
+	for _, name := range []string{"a.txt", ".a.txt.tmp-123"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("a\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := r.GetChangedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0] != "a.txt" {
+		t.Errorf("GetChangedFiles = %v, want [a.txt]", files)
+	}
+}
@@ -0,0 +1,383 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// ChangesetsRef holds a commit per changeset whose tree has the latest
+	// version of every saved file at the time
+	ChangesetsRef plumbing.ReferenceName = "refs/versionctrls/changesets"
+
+	changesetTrailer = "Changeset"
+	savedTrailer     = "Saved"
+	deletedTrailer   = "Deleted"
+)
+
+// ErrNoChangeset is returned when no changeset matches
+var ErrNoChangeset = errors.New("no such changeset")
+
+// Changeset is a group of versions saved together in one run
+type Changeset struct {
+	ID      string    `json:"id"`
+	Hash    string    `json:"hash"`
+	When    time.Time `json:"timestamp"`
+	Author  string    `json:"author"`
+	Saved   []string  `json:"saved"`
+	Deleted []string  `json:"deleted,omitempty"`
+
+	tree plumbing.Hash
+}
+
+// pendingChangeset collects the files saved since BeginChangeset
+type pendingChangeset struct {
+	id      string
+	saved   []string
+	deleted []string
+}
+
+// record notes that path was saved, or deleted when removed is set
+func (c *pendingChangeset) record(path string, removed bool) {
+	if c == nil {
+		return
+	}
+	if removed {
+		c.deleted = append(c.deleted, path)
+	} else {
+		c.saved = append(c.saved, path)
+	}
+}
+
+// BeginChangeset groups the versions saved from now on, until
+// CommitChangeset, and tags each of them with the returned changeset id
+func (r *Repository) BeginChangeset() string {
+	id := newID()
+	r.changeset = &pendingChangeset{id: id}
+	return id
+}
+
+// newID returns an id that sorts by the time it was made, with a random
+// suffix so ids made in the same second on different machines differ
+func newID() string {
+	random := make([]byte, 3)
+	rand.Read(random)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(random)
+}
+
+// InChangeset reports whether a changeset was begun and not committed yet
+func (r Repository) InChangeset() bool {
+	return r.changeset != nil
+}
+
+// CommitChangeset ends the changeset begun with BeginChangeset with a commit
+// on ChangesetsRef and queues it for push. It returns plumbing.ZeroHash when
+// nothing was saved in it.
+func (r *Repository) CommitChangeset() (plumbing.Hash, error) {
+	pending := r.changeset
+	r.changeset = nil
+	if pending == nil || len(pending.saved)+len(pending.deleted) == 0 {
+		return plumbing.ZeroHash, nil
+	}
+
+	ref, tip, err := r.refTip(ChangesetsRef)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	var parents []plumbing.Hash
+	if tip != nil {
+		parents = []plumbing.Hash{tip.Hash}
+	}
+
+	tree, err := r.changesetTree(tip, pending)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not build changeset tree: %w", err)
+	}
+
+	sort.Strings(pending.saved)
+	sort.Strings(pending.deleted)
+	trailers := []Trailer{{changesetTrailer, pending.id}}
+	for _, path := range pending.saved {
+		trailers = append(trailers, Trailer{savedTrailer, path})
+	}
+	for _, path := range pending.deleted {
+		trailers = append(trailers, Trailer{deletedTrailer, path})
+	}
+	files := len(pending.saved) + len(pending.deleted)
+	subject := fmt.Sprintf("Changeset %s, %d file", pending.id, files)
+	if files != 1 {
+		subject += "s"
+	}
+
+	commit, err := r.storeCommit(tree, parents, buildMessage(subject, trailers...))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not store changeset: %w", err)
+	}
+	if err := r.advanceRef(ChangesetsRef, commit, ref); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := r.QueueRef(ChangesetsRef, "changesets"); err != nil {
+		return commit, fmt.Errorf("could not queue changesets for push: %w", err)
+	}
+	return commit, nil
+}
+
+// changesetTree stores the tree of pending, which is the tree of the
+// changeset before it, tip, with the files saved and deleted since updated.
+// The first changeset starts from the latest version of every saved file.
+func (r Repository) changesetTree(tip *object.Commit, pending *pendingChangeset) (plumbing.Hash, error) {
+	if tip == nil {
+		return r.latestTree()
+	}
+
+	tree := tip.TreeHash
+	for _, path := range append(append([]string{}, pending.deleted...), pending.saved...) {
+		refName, err := r.FileRefName(path)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		_, file, err := r.refTip(refName)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		// Deleted files, and the old paths of renamed ones, have no entry
+		// left at the tip of their reference
+		var entry *object.TreeEntry
+		if file != nil {
+			entry, err = r.findTreeEntry(file.TreeHash, path)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+		}
+		tree, err = r.updateTree(tree, path, entry)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+	if tree.IsZero() {
+		// Every file was deleted
+		return r.storeTree(nil)
+	}
+	return tree, nil
+}
+
+// latestTree stores a tree with the latest version of every saved file
+func (r Repository) latestTree() (plumbing.Hash, error) {
+	refs, err := r.FileRefs()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	root := &treeNode{}
+	for refName, path := range refs {
+		_, tip, err := r.refTip(refName)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entry, err := r.findTreeEntry(tip.TreeHash, path)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if entry != nil {
+			root.add(strings.Split(path, "/"), *entry)
+		}
+	}
+	return root.store(r)
+}
+
+// treeNode is a directory of a tree being built in memory
+type treeNode struct {
+	files map[string]object.TreeEntry
+	dirs  map[string]*treeNode
+}
+
+func (n *treeNode) add(parts []string, entry object.TreeEntry) {
+	if len(parts) == 1 {
+		if n.files == nil {
+			n.files = map[string]object.TreeEntry{}
+		}
+		entry.Name = parts[0]
+		n.files[parts[0]] = entry
+		return
+	}
+
+	if n.dirs == nil {
+		n.dirs = map[string]*treeNode{}
+	}
+	dir, ok := n.dirs[parts[0]]
+	if !ok {
+		dir = &treeNode{}
+		n.dirs[parts[0]] = dir
+	}
+	dir.add(parts[1:], entry)
+}
+
+func (n *treeNode) store(r Repository) (plumbing.Hash, error) {
+	var entries []object.TreeEntry
+	for _, entry := range n.files {
+		entries = append(entries, entry)
+	}
+	for name, dir := range n.dirs {
+		if _, clash := n.files[name]; clash {
+			// A file saved under a path that later became a directory
+			continue
+		}
+		hash, err := dir.store(r)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+	}
+	return r.storeTree(entries)
+}
+
+// Changesets returns every changeset, newest first
+func (r Repository) Changesets() ([]Changeset, error) {
+	commits, err := r.firstParents(ChangesetsRef)
+	if err != nil {
+		return nil, err
+	}
+
+	var changesets []Changeset
+	for _, commit := range commits {
+		if id, ok := commitTrailer(commit.Message, changesetTrailer); ok {
+			changesets = append(changesets, Changeset{
+				ID:      id,
+				Hash:    commit.Hash.String(),
+				When:    commit.Author.When,
+				Author:  commit.Author.Name,
+				Saved:   commitTrailers(commit.Message, savedTrailer),
+				Deleted: commitTrailers(commit.Message, deletedTrailer),
+				tree:    commit.TreeHash,
+			})
+		}
+	}
+	return changesets, nil
+}
+
+// firstParents returns the commits of refName from its tip back along
+// first parents, or nothing when it does not exist
+func (r Repository) firstParents(refName plumbing.ReferenceName) ([]*object.Commit, error) {
+	_, commit, err := r.refTip(refName)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []*object.Commit
+	for commit != nil {
+		commits = append(commits, commit)
+		if commit.NumParents() == 0 {
+			break
+		}
+		commit, err = commit.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return commits, nil
+}
+
+// FindChangeset returns the changeset whose id or commit hash starts with
+// name
+func FindChangeset(changesets []Changeset, name string) (Changeset, error) {
+	for _, changeset := range changesets {
+		if changeset.ID == name || (len(name) >= 4 && (strings.HasPrefix(changeset.ID, name) || strings.HasPrefix(changeset.Hash, name))) {
+			return changeset, nil
+		}
+	}
+	return Changeset{}, fmt.Errorf("%s: %w", name, ErrNoChangeset)
+}
+
+// ChangesetAt returns the last changeset saved at or before t
+func ChangesetAt(changesets []Changeset, t time.Time) (Changeset, error) {
+	for _, changeset := range changesets {
+		if !changeset.When.After(t) {
+			return changeset, nil
+		}
+	}
+	return Changeset{}, fmt.Errorf("nothing was saved by %s: %w", t.Local().Format("2006-01-02 15:04"), ErrNoChangeset)
+}
+
+// ChangesetVersions returns the versions of the files saved in changeset
+// or, with all set, of every file saved by then, sorted by path
+func (r Repository) ChangesetVersions(changeset Changeset, all bool) ([]Version, error) {
+	paths := append([]string{}, changeset.Saved...)
+	if all {
+		var err error
+		paths, err = r.treeFiles(changeset.tree, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.treeVersions(changeset.tree, paths, Version{
+		Hash:    changeset.Hash,
+		Label:   changeset.ID,
+		When:    changeset.When,
+		Author:  changeset.Author,
+		Message: "changeset " + changeset.ID,
+	})
+}
+
+// treeVersions returns a copy of base for each of paths found in tree,
+// sorted by path, with the path, size and content of the file filled in
+func (r Repository) treeVersions(tree plumbing.Hash, paths []string, base Version) ([]Version, error) {
+	sort.Strings(paths)
+
+	var versions []Version
+	for _, path := range paths {
+		entry, err := r.findTreeEntry(tree, path)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		size, err := r.entrySize(entry.Hash, entry.Mode)
+		if err != nil {
+			return nil, err
+		}
+		v := base
+		v.Path = path
+		v.Size = size
+		v.blob = entry.Hash
+		v.mode = entry.Mode
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// treeFiles returns the paths of the files in tree below dir, counting
+// the chunks of a large file as the file
+func (r Repository) treeFiles(tree plumbing.Hash, dir string) ([]string, error) {
+	entries, err := r.treeEntries(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		path := dir + entry.Name
+		if entry.Mode != filemode.Dir || r.isChunks(entry.Hash) {
+			paths = append(paths, path)
+			continue
+		}
+		below, err := r.treeFiles(entry.Hash, path+"/")
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, below...)
+	}
+	return paths, nil
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCommitChangeset(t *testing.T) {
+	testUser(t)
+	r, dir := newClone(t, newRemote(t))
+
+	commit := func() Changeset {
+		t.Helper()
+		hash, err := r.CommitChangeset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		changesets, err := r.Changesets()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash.IsZero() || changesets[0].Hash != hash.String() {
+			t.Fatalf("CommitChangeset = %s, the latest changeset is %+v", hash, changesets[0])
+		}
+		return changesets[0]
+	}
+	files := func(c Changeset) []string {
+		t.Helper()
+		paths, err := r.treeFiles(c.tree, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return paths
+	}
+
+	// A file saved before changesets is in the first one
+	save(t, r, dir, "old.txt", "old\n")
+
+	id := r.BeginChangeset()
+	save(t, r, dir, "a.txt", "a\n")
+	save(t, r, dir, "dir/b.txt", "b\n")
+	first := commit()
+	if first.ID != id || !reflect.DeepEqual(first.Saved, []string{"a.txt", "dir/b.txt"}) {
+		t.Errorf("first changeset = %+v, want %s saving a.txt and dir/b.txt", first, id)
+	}
+	if got := files(first); !reflect.DeepEqual(got, []string{"a.txt", "dir/b.txt", "old.txt"}) {
+		t.Errorf("first changeset holds %v", got)
+	}
+
+	r.BeginChangeset()
+	save(t, r, dir, "a.txt", "a2\n")
+	if err := os.Remove(filepath.Join(dir, "dir", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DeleteFile("dir/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	second := commit()
+	if !reflect.DeepEqual(second.Saved, []string{"a.txt"}) || !reflect.DeepEqual(second.Deleted, []string{"dir/b.txt"}) {
+		t.Errorf("second changeset = %+v, want a.txt saved and dir/b.txt deleted", second)
+	}
+	if got := files(second); !reflect.DeepEqual(got, []string{"a.txt", "old.txt"}) {
+		t.Errorf("second changeset holds %v", got)
+	}
+	versions, err := r.ChangesetVersions(second, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range versions {
+		content, err := r.ReadVersion(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"a.txt": "a2\n", "old.txt": "old\n"}[v.Path]
+		if string(content) != want {
+			t.Errorf("%s in the second changeset is %q, want %q", v.Path, content, want)
+		}
+	}
+
+	// Built on top of the one before, the tree is the same as one built
+	// from every file
+	latest, err := r.latestTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.tree != latest {
+		t.Errorf("changeset tree %s, tree of the latest versions %s", second.tree, latest)
+	}
+
+	// Nothing saved, nothing committed
+	r.BeginChangeset()
+	if hash, err := r.CommitChangeset(); err != nil || !hash.IsZero() {
+		t.Errorf("empty CommitChangeset = %s, %v", hash, err)
+	}
+	if r.InChangeset() {
+		t.Error("still in a changeset after committing it")
+	}
+}
+
+func TestFindChangeset(t *testing.T) {
+	now := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	changesets := []Changeset{
+		{ID: "20240501T140000-aaaaaa", Hash: "1234567890abcdef", When: now},
+		{ID: "20240501T120000-bbbbbb", Hash: "abcdef1234567890", When: now.Add(-2 * time.Hour)},
+	}
+
+	for _, tc := range []struct {
+		name string
+		want string
+	}{
+		{"20240501T120000-bbbbbb", "20240501T120000-bbbbbb"},
+		{"20240501T14", "20240501T140000-aaaaaa"},
+		{"abcdef12", "20240501T120000-bbbbbb"},
+		{"123", ""},
+		{"nothing", ""},
+	} {
+		c, err := FindChangeset(changesets, tc.name)
+		if tc.want == "" {
+			if !errors.Is(err, ErrNoChangeset) {
+				t.Errorf("FindChangeset(%s) = %s, %v, want ErrNoChangeset", tc.name, c.ID, err)
+			}
+			continue
+		}
+		if err != nil || c.ID != tc.want {
+			t.Errorf("FindChangeset(%s) = %s, %v, want %s", tc.name, c.ID, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		at   time.Time
+		want string
+	}{
+		{now.Add(time.Minute), "20240501T140000-aaaaaa"},
+		{now, "20240501T140000-aaaaaa"},
+		{now.Add(-time.Hour), "20240501T120000-bbbbbb"},
+		{now.Add(-3 * time.Hour), ""},
+	} {
+		c, err := ChangesetAt(changesets, tc.at)
+		if tc.want == "" {
+			if !errors.Is(err, ErrNoChangeset) {
+				t.Errorf("ChangesetAt(%s) = %s, %v, want ErrNoChangeset", tc.at, c.ID, err)
+			}
+			continue
+		}
+		if err != nil || c.ID != tc.want {
+			t.Errorf("ChangesetAt(%s) = %s, %v, want %s", tc.at, c.ID, err, tc.want)
+		}
+	}
+}
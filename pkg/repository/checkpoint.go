@@ -0,0 +1,375 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/renatonmag/versionctrls-cli/pkg/secrets"
+)
+
+const (
+	// CheckpointsRef holds a commit per checkpoint whose tree is the HEAD
+	// tree of the project with the changed files of the worktree on top
+	CheckpointsRef plumbing.ReferenceName = "refs/versionctrls/checkpoints"
+
+	checkpointTrailer = "Checkpoint"
+	headTrailer       = "Head"
+	changedTrailer    = "Changed"
+	recipientsTrailer = "Recipients"
+)
+
+// ErrNoCheckpoint is returned when no checkpoint matches
+var ErrNoCheckpoint = errors.New("no such checkpoint")
+
+// Checkpoint is a snapshot of the whole worktree of the project
+type Checkpoint struct {
+	ID      string    `json:"id"`
+	Hash    string    `json:"hash"`
+	When    time.Time `json:"timestamp"`
+	Author  string    `json:"author"`
+	Message string    `json:"message"`
+	// Head is the project commit the checkpoint was taken on, empty when
+	// the project had no commits yet
+	Head    string   `json:"head,omitempty"`
+	Changed []string `json:"changed"`
+	Deleted []string `json:"deleted,omitempty"`
+
+	tree plumbing.Hash
+}
+
+// CreateCheckpoint stores the worktree of project, its HEAD tree with the
+// changed files that are not ignored on top, as a new checkpoint. Nothing
+// in project is touched. Files that are blocked for secrets or too large
+// to store are left out with a warning. HEAD files that are as they were
+// when the last checkpoint was taken are taken over from it.
+func (r Repository) CreateCheckpoint(project Repository, message string) (Checkpoint, error) {
+	if r.repo == nil || project.repo == nil {
+		return Checkpoint{}, errors.New("no repository opened")
+	}
+
+	recipients, err := r.keys.Recipients()
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	last, err := r.lastCheckpoint(project, recipients)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	files := map[string]object.TreeEntry{}
+
+	var head plumbing.Hash
+	ref, err := project.repo.Head()
+	switch {
+	case err == plumbing.ErrReferenceNotFound:
+		// Nothing committed yet, every file is a change
+	case err != nil:
+		return Checkpoint{}, err
+	default:
+		commit, err := project.repo.CommitObject(ref.Hash())
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		head = commit.Hash
+
+		tree, err := commit.Tree()
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		err = tree.Files().ForEach(func(f *object.File) error {
+			entry, err := last.unchanged(r, f)
+			if err != nil {
+				return err
+			}
+			if entry != nil {
+				files[f.Name] = *entry
+				return nil
+			}
+
+			content, err := project.readBlob(f.Hash)
+			if err != nil {
+				return err
+			}
+			previous, err := r.findTreeEntry(last.tree, f.Name)
+			if err != nil {
+				return err
+			}
+			entry, err = r.checkpointEntry(f.Name, content, f.Mode, previous)
+			if err != nil {
+				return err
+			}
+			if entry != nil {
+				files[f.Name] = *entry
+			}
+			return nil
+		})
+		if err != nil {
+			return Checkpoint{}, fmt.Errorf("could not store HEAD tree: %w", err)
+		}
+	}
+
+	changes, err := project.FileChanges()
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	root, err := project.worktreeRoot()
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	var changed, deleted []string
+	for _, change := range changes {
+		if change.Kind == Renamed {
+			delete(files, change.From)
+			deleted = append(deleted, change.From)
+		}
+		if change.Kind == Deleted {
+			delete(files, change.Path)
+			deleted = append(deleted, change.Path)
+			continue
+		}
+
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(change.Path)))
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		if info.IsDir() {
+			// Nested repositories, such as the integration submodule
+			continue
+		}
+
+		content, mode, err := project.readWorktreeFile(change.Path)
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		previous, err := r.findTreeEntry(last.tree, change.Path)
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		entry, err := r.checkpointEntry(change.Path, content, mode, previous)
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		if entry == nil {
+			delete(files, change.Path)
+			continue
+		}
+		files[change.Path] = *entry
+		changed = append(changed, change.Path)
+	}
+	sort.Strings(deleted)
+
+	tree := &treeNode{}
+	for path, entry := range files {
+		tree.add(strings.Split(path, "/"), entry)
+	}
+	treeHash, err := tree.store(r)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("could not store checkpoint tree: %w", err)
+	}
+
+	id := newID()
+	if message == "" {
+		message = "Checkpoint " + id
+	}
+	trailers := []Trailer{{checkpointTrailer, id}}
+	if !head.IsZero() {
+		trailers = append(trailers, Trailer{headTrailer, head.String()})
+	}
+	if recipients != "" {
+		trailers = append(trailers, Trailer{recipientsTrailer, recipients})
+	}
+	for _, path := range changed {
+		trailers = append(trailers, Trailer{changedTrailer, path})
+	}
+	for _, path := range deleted {
+		trailers = append(trailers, Trailer{deletedTrailer, path})
+	}
+
+	tipRef, tip, err := r.refTip(CheckpointsRef)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	var parents []plumbing.Hash
+	if tip != nil {
+		parents = []plumbing.Hash{tip.Hash}
+	}
+
+	commitHash, err := r.storeCommit(treeHash, parents, buildMessage(message, trailers...))
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("could not store checkpoint: %w", err)
+	}
+	if err := r.advanceRef(CheckpointsRef, commitHash, tipRef); err != nil {
+		return Checkpoint{}, err
+	}
+	if err := r.QueueRef(CheckpointsRef, "checkpoints"); err != nil {
+		return Checkpoint{}, fmt.Errorf("could not queue checkpoints for push: %w", err)
+	}
+
+	commit, err := r.repo.CommitObject(commitHash)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return checkpointFromCommit(commit), nil
+}
+
+// lastCheckpoint is what a new checkpoint can take over from the latest one
+type lastCheckpoint struct {
+	tree plumbing.Hash
+	// head is the project HEAD tree the checkpoint was taken on, nil when
+	// its entries cannot be taken over as they are
+	head    *object.Tree
+	changed map[string]bool
+}
+
+// lastCheckpoint returns the latest checkpoint. Its entries are only taken
+// over when it was encrypted for recipients, and the HEAD it was taken on
+// is still in project.
+func (r Repository) lastCheckpoint(project Repository, recipients string) (lastCheckpoint, error) {
+	_, tip, err := r.refTip(CheckpointsRef)
+	if err != nil || tip == nil {
+		return lastCheckpoint{}, err
+	}
+	if _, ok := commitTrailer(tip.Message, checkpointTrailer); !ok {
+		return lastCheckpoint{}, nil
+	}
+
+	last := lastCheckpoint{tree: tip.TreeHash, changed: map[string]bool{}}
+	for _, path := range commitTrailers(tip.Message, changedTrailer) {
+		last.changed[path] = true
+	}
+
+	had, _ := commitTrailer(tip.Message, recipientsTrailer)
+	head, ok := commitTrailer(tip.Message, headTrailer)
+	if had != recipients || !ok {
+		return last, nil
+	}
+	commit, err := project.repo.CommitObject(plumbing.NewHash(head))
+	if err != nil {
+		// HEAD may have been rewritten since, nothing is taken over then
+		return last, nil
+	}
+	last.head, err = commit.Tree()
+	return last, err
+}
+
+// unchanged returns the entry of the last checkpoint for f when it holds
+// the same HEAD content, or nil when f has to be stored again
+func (l lastCheckpoint) unchanged(r Repository, f *object.File) (*object.TreeEntry, error) {
+	if l.head == nil || l.changed[f.Name] {
+		return nil, nil
+	}
+
+	was, err := l.head.FindEntry(f.Name)
+	if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if was.Hash != f.Hash || was.Mode != f.Mode {
+		return nil, nil
+	}
+
+	// Files left out of the last checkpoint are not in its tree
+	return r.findTreeEntry(l.tree, f.Name)
+}
+
+// checkpointEntry stores content for path the way snapshots are stored,
+// reusing what it can from previous, the entry of the last checkpoint.
+// It returns nil for files that are left out of the checkpoint.
+func (r Repository) checkpointEntry(path string, content []byte, mode filemode.FileMode, previous *object.TreeEntry) (*object.TreeEntry, error) {
+	if mode != filemode.Symlink {
+		redacted, _, err := r.checkSecrets(path, content)
+		if errors.Is(err, secrets.ErrSecretFound) {
+			fmt.Printf("Leaving %s out of the checkpoint: %v\n", path, err)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		content = redacted
+	}
+
+	entry, err := r.storeContent(path, content, mode, previous)
+	if errors.Is(err, ErrFileTooLarge) {
+		fmt.Printf("Leaving %s out of the checkpoint: %v\n", path, err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not store content of %s: %w", path, err)
+	}
+	return &entry, nil
+}
+
+// Checkpoints returns every checkpoint, newest first
+func (r Repository) Checkpoints() ([]Checkpoint, error) {
+	commits, err := r.firstParents(CheckpointsRef)
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoints []Checkpoint
+	for _, commit := range commits {
+		if _, ok := commitTrailer(commit.Message, checkpointTrailer); ok {
+			checkpoints = append(checkpoints, checkpointFromCommit(commit))
+		}
+	}
+	return checkpoints, nil
+}
+
+// checkpointFromCommit reads a checkpoint back from its commit
+func checkpointFromCommit(commit *object.Commit) Checkpoint {
+	id, _ := commitTrailer(commit.Message, checkpointTrailer)
+	head, _ := commitTrailer(commit.Message, headTrailer)
+	return Checkpoint{
+		ID:      id,
+		Hash:    commit.Hash.String(),
+		When:    commit.Author.When,
+		Author:  commit.Author.Name,
+		Message: commitSubject(commit.Message),
+		Head:    head,
+		Changed: commitTrailers(commit.Message, changedTrailer),
+		Deleted: commitTrailers(commit.Message, deletedTrailer),
+		tree:    commit.TreeHash,
+	}
+}
+
+// FindCheckpoint returns the checkpoint whose id or commit hash starts
+// with name
+func FindCheckpoint(checkpoints []Checkpoint, name string) (Checkpoint, error) {
+	for _, checkpoint := range checkpoints {
+		if checkpoint.ID == name || (len(name) >= 4 && (strings.HasPrefix(checkpoint.ID, name) || strings.HasPrefix(checkpoint.Hash, name))) {
+			return checkpoint, nil
+		}
+	}
+	return Checkpoint{}, fmt.Errorf("%s: %w", name, ErrNoCheckpoint)
+}
+
+// CheckpointVersions returns the versions of the files changed in
+// checkpoint or, with all set, of every file in it, sorted by path
+func (r Repository) CheckpointVersions(checkpoint Checkpoint, all bool) ([]Version, error) {
+	paths := append([]string{}, checkpoint.Changed...)
+	if all {
+		var err error
+		paths, err = r.treeFiles(checkpoint.tree, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.treeVersions(checkpoint.tree, paths, Version{
+		Hash:    checkpoint.Hash,
+		Label:   checkpoint.ID,
+		When:    checkpoint.When,
+		Author:  checkpoint.Author,
+		Message: checkpoint.Message,
+	})
+}
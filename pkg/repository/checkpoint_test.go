@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/encryption"
+)
+
+func TestCheckpointTakesOverUnchangedFiles(t *testing.T) {
+	testUser(t)
+	r, _ := newClone(t, newRemote(t))
+	useKeys := func(salt string) {
+		t.Helper()
+		keys, err := encryption.Load(config.Encryption{Method: config.EncryptPassphrase, Salt: salt}, func() (string, error) {
+			return "hunter2", nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.SetEncryption(keys)
+	}
+	useKeys("test")
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := &Repository{repo: repo}
+	write := func(file, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt", "a\n")
+	write("b.txt", "b\n")
+	worktree, _ := repo.Worktree()
+	worktree.Add(".")
+	if _, err := worktree.Commit("Add a and b", &git.CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint := func() Checkpoint {
+		t.Helper()
+		c, err := r.CreateCheckpoint(*project, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	entry := func(c Checkpoint, path string) *object.TreeEntry {
+		t.Helper()
+		e, err := r.findTreeEntry(c.tree, path)
+		if err != nil || e == nil {
+			t.Fatalf("%s is not in checkpoint %s: %v", path, c.ID, err)
+		}
+		return e
+	}
+
+	write("b.txt", "edited\n")
+	first := checkpoint()
+
+	// Encrypted blobs differ each time they are stored, so an equal hash
+	// means the entry was taken over
+	write("b.txt", "b\n")
+	second := checkpoint()
+	if entry(second, "a.txt").Hash != entry(first, "a.txt").Hash {
+		t.Error("a.txt was stored again although it did not change")
+	}
+	if entry(second, "b.txt").Hash == entry(first, "b.txt").Hash {
+		t.Error("b.txt was taken over from the worktree edit in the last checkpoint")
+	}
+	versions, err := r.CheckpointVersions(second, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range versions {
+		content, err := r.ReadVersion(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := v.Path[:1] + "\n"; string(content) != want {
+			t.Errorf("%s reads back as %q, want %q", v.Path, content, want)
+		}
+	}
+
+	// Entries encrypted for other recipients are stored again
+	useKeys("other")
+	third := checkpoint()
+	if entry(third, "a.txt").Hash == entry(second, "a.txt").Hash {
+		t.Error("a.txt was taken over although the recipients changed")
+	}
+}
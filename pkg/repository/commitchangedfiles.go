@@ -1,27 +1,53 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
 	"log"
-	"path/filepath"
-	"strings"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/secrets"
 )
 
-// CreateCommitForChangedFiles creates a commit for each changed file in its own branch
-func (r *Repository) CommitChangedFiles() error {
+// CreateCommitForChangedFiles creates a commit for each changed file in its own branch.
+// The commits form a changeset, unless one was begun already and the caller
+// commits it.
+func (r *Repository) CommitChangedFiles() (err error) {
 	changedFiles, err := r.GetChangedFiles()
 	if err != nil {
 		log.Printf("Error getting changed files: %s\n", err)
 		return err
 	}
 
-	for _, file := range changedFiles {
-		// Replace path separators with a character allowed in branch names (e.g., "-")
-		branchName := strings.ReplaceAll(file, string(filepath.Separator), "-")
+	if !r.InChangeset() {
+		r.BeginChangeset()
+		defer func() {
+			commit, changesetErr := r.CommitChangeset()
+			if changesetErr != nil && err == nil {
+				err = fmt.Errorf("could not commit changeset: %w", changesetErr)
+			}
+			if !commit.IsZero() {
+				fmt.Println("Changeset committed:", commit)
+			}
+		}()
+	}
 
-		err = r.CommitChanges(file, branchName)
+	for _, file := range changedFiles {
+		commit, err := r.SaveFile(file)
+		if errors.Is(err, ErrFileTooLarge) || errors.Is(err, secrets.ErrSecretFound) {
+			fmt.Printf("Skipping %v\n", err)
+			continue
+		}
 		if err != nil {
-			return err
+			return fmt.Errorf("could not commit changes: %w", err)
+		}
+
+		if commit.IsZero() {
+			fmt.Printf("No changes in %s\n", file)
+			continue
 		}
+
+		// Print the commit hash
+		fmt.Println("Commit successful:", commit)
 	}
 
 	return nil
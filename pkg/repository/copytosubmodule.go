@@ -1,55 +1,120 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/secrets"
 	"github.com/renatonmag/versionctrls-cli/pkg/utils"
 )
 
-const maxFileSize = 1000 * 1024 // 1mb in bytes
-
-// CopyChangedFilesToSubmodule copies all changed files from the root repository to the submodule
+// CopyChangedFilesToSubmodule copies all changed files from the root
+// repository to the submodule, removing deleted files and moving renamed
+// ones there too
 func (r Repository) CopyChangedFilesToSubmodule() error {
-	changedFiles, err := r.GetChangedFiles()
+	changes, err := r.FileChanges()
 	if err != nil {
 		return err
 	}
 
-	rootPath, _ := r.GetRepoRoot()
-
-	for _, file := range changedFiles {
-		srcPath := filepath.Join(rootPath, file)
-		dstPath := filepath.Join(r.submodulePath, file)
-
-		fileInfo, err := os.Stat(srcPath)
+	for _, change := range changes {
+		_, err := r.MirrorChange(change)
 		if err != nil {
-			if os.IsNotExist(err) {
-				fmt.Printf("Skipping %s as it does not exist\n", srcPath)
-				continue
-			}
 			return err
 		}
+	}
 
-		if fileInfo.Size() > maxFileSize {
-			fmt.Printf("Skipping %s as it exceeds the file size limit of 500KB\n", srcPath)
-			continue
-		}
+	return nil
+}
 
-		// Ensure the destination directory exists
-		dstDir := filepath.Dir(dstPath)
-		err = os.MkdirAll(dstDir, os.ModePerm)
-		if err != nil {
-			return err
+// CopyFileToSubmodule copies a single file from the root repository to the
+// submodule, reporting whether it was copied or skipped
+func (r Repository) CopyFileToSubmodule(file string) (bool, error) {
+	rootPath, _ := r.GetRepoRoot()
+	submodulePath, err := r.IntegrationSubmodulePath()
+	if err != nil {
+		return false, err
+	}
+
+	srcPath := filepath.Join(rootPath, file)
+	dstPath := filepath.Join(submodulePath, file)
+
+	if r.Ignored(file) {
+		fmt.Printf("Skipping %s as it is ignored\n", srcPath)
+		return false, nil
+	}
+
+	fileInfo, err := os.Lstat(srcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Printf("Skipping %s as it does not exist\n", srcPath)
+			return false, nil
 		}
+		return false, err
+	}
 
-		err = utils.CopyFile(srcPath, dstPath)
-		if err != nil {
-			return err
+	if ok, warning := r.CheckSize(file, fileInfo.Size()); !ok {
+		fmt.Println(warning)
+		return false, nil
+	}
+
+	// Ensure the destination directory exists
+	dstDir := filepath.Dir(dstPath)
+	err = os.MkdirAll(dstDir, os.ModePerm)
+	if err != nil {
+		return false, err
+	}
+
+	if fileInfo.Mode().IsRegular() {
+		copied, done, err := r.copyScanned(file, srcPath, dstPath, fileInfo)
+		if done || err != nil {
+			return copied, err
 		}
-		fmt.Printf("Copied %s to %s\n", srcPath, dstPath)
 	}
 
-	return nil
+	err = utils.CopyFile(srcPath, dstPath)
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("Copied %s to %s\n", srcPath, dstPath)
+
+	return true, nil
+}
+
+// copyScanned checks the file at srcPath for secrets before it is copied.
+// Blocked files are skipped and redacted ones written to dstPath with the
+// secrets replaced. done is false when the file is clean and still needs
+// copying.
+func (r Repository) copyScanned(file, srcPath, dstPath string, fileInfo os.FileInfo) (copied bool, done bool, err error) {
+	if r.secrets.Action() == config.SecretsOff {
+		return false, false, nil
+	}
+
+	content, err := os.ReadFile(srcPath)
+	if err != nil {
+		return false, true, err
+	}
+
+	redacted, findings, err := r.checkSecrets(filepath.ToSlash(file), content)
+	if errors.Is(err, secrets.ErrSecretFound) {
+		fmt.Printf("Warning: skipping %v. Run ctrls scan for details, and add the file or fingerprint to %s if it is fine to version.\n", err, secrets.AllowlistFile)
+		return false, true, nil
+	}
+	if err != nil || len(findings) == 0 {
+		return false, err != nil, err
+	}
+
+	if err := utils.WriteFileAtomic(dstPath, redacted, fileInfo.Mode().Perm()); err != nil {
+		return false, true, err
+	}
+	// Like CopyFile, keep the modification time of the original
+	if err := os.Chtimes(dstPath, time.Time{}, fileInfo.ModTime()); err != nil {
+		return false, true, err
+	}
+	fmt.Printf("Copied %s to %s with %d possible secrets redacted\n", srcPath, dstPath, len(findings))
+	return true, true, nil
 }
@@ -2,11 +2,9 @@ package repository
 
 import (
 	"log"
-	"path/filepath"
-	"strings"
 )
 
-// CreateEmptyBranchesForChangedFiles creates an empty branch for each changed file with its path as the branch name
+// CreateEmptyBranchesForChangedFiles creates an empty branch for each changed file named after its path
 func (r Repository) CreateEmptyBranchesForChangedFiles() error {
 	changedFiles, err := r.GetChangedFiles()
 	if err != nil {
@@ -14,12 +12,9 @@ func (r Repository) CreateEmptyBranchesForChangedFiles() error {
 	}
 
 	for _, file := range changedFiles {
-		// Replace path separators with a character allowed in branch names (e.g., "-")
-		branchName := strings.ReplaceAll(file, string(filepath.Separator), "-")
-
-		err := r.CreateEmptyBranch2(branchName)
+		err := r.CreateEmptyFileRef(file)
 		if err != nil {
-			log.Printf("Failed to create branch %s: %s\n", branchName, err)
+			log.Printf("Failed to create branch for %s: %s\n", file, err)
 			return err
 		}
 	}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// DeleteFile records that file was deleted with a tombstone commit on its
+// branch, a commit whose tree no longer holds the file, and queues the branch
+// for push. Its versions stay reachable behind the tombstone. It returns
+// plumbing.ZeroHash when the file was never saved or is already deleted.
+func (r Repository) DeleteFile(file string) (plumbing.Hash, error) {
+	if r.repo == nil {
+		return plumbing.ZeroHash, errors.New("no repository opened")
+	}
+
+	file = filepath.ToSlash(file)
+	return r.tombstone(file, buildMessage(file+" deleted", Trailer{pathTrailer, file}), false)
+}
+
+// tombstone commits the removal of file from the tip of its branch with the
+// given message. Unless force is set, nothing is committed when the tip does
+// not hold the file.
+func (r Repository) tombstone(file, message string, force bool) (plumbing.Hash, error) {
+	refName, err := r.FileRefName(file)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ref, tip, err := r.refTip(refName)
+	if err != nil || tip == nil {
+		return plumbing.ZeroHash, err
+	}
+
+	entry, err := r.findTreeEntry(tip.TreeHash, file)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if entry == nil && !force {
+		return plumbing.ZeroHash, nil
+	}
+
+	tree, err := r.updateTree(tip.TreeHash, file, nil)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not build tree for %s: %w", file, err)
+	}
+
+	if r.changeset != nil {
+		message = addTrailer(message, Trailer{changesetTrailer, r.changeset.id})
+	}
+	commit, err := r.storeCommit(tree, []plumbing.Hash{tip.Hash}, message)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not store commit for %s: %w", file, err)
+	}
+
+	if err := r.advanceRef(refName, commit, ref); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	r.changeset.record(file, true)
+
+	if err := r.QueueRef(refName, file); err != nil {
+		return commit, fmt.Errorf("could not queue %s for push: %w", file, err)
+	}
+	return commit, nil
+}
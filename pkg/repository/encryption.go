@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/renatonmag/versionctrls-cli/pkg/encryption"
+)
+
+// prefixSize is enough of the start of encrypted content to hold its
+// header with a few dozen recipients
+const prefixSize = 16 * 1024
+
+// SetEncryption sets the keyring that encrypts content before it is stored
+// and decrypts it when it is read. A nil keyring stores content as it is.
+func (r *Repository) SetEncryption(keys *encryption.Keyring) {
+	r.keys = keys
+}
+
+// Keyring returns the keyring set with SetEncryption
+func (r Repository) Keyring() *encryption.Keyring {
+	return r.keys
+}
+
+// storedPrefix returns up to prefixSize bytes from the start of the content
+// recorded by a tree entry, as it was stored
+func (r Repository) storedPrefix(hash plumbing.Hash, mode filemode.FileMode) ([]byte, error) {
+	if mode == filemode.Dir {
+		entries, err := r.treeEntries(hash)
+		if err != nil || len(entries) == 0 {
+			return nil, err
+		}
+		hash = entries[0].Hash
+	}
+
+	prefix, err := r.readBlobPrefix(hash)
+	if err != nil || mode == filemode.Dir {
+		return prefix, err
+	}
+
+	oid, _, ok := parseLFSPointer(prefix)
+	if !ok {
+		return prefix, nil
+	}
+	path, err := r.lfsObjectPath(oid)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Without the object the size of the pointer is all there is
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readPrefix(f)
+}
+
+// readBlobPrefix returns up to prefixSize bytes from the start of a blob
+func (r Repository) readBlobPrefix(hash plumbing.Hash) ([]byte, error) {
+	blob, err := r.repo.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return readPrefix(reader)
+}
+
+func readPrefix(reader io.Reader) ([]byte, error) {
+	prefix := make([]byte, prefixSize)
+	n, err := io.ReadFull(reader, prefix)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return prefix[:n], err
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// ChangeKind is what happened to a file in the worktree
+type ChangeKind int
+
+const (
+	// Modified files exist in HEAD and were edited
+	Modified ChangeKind = iota
+	// Added files are untracked or newly staged
+	Added
+	// Deleted files are gone from the worktree
+	Deleted
+	// Renamed files are added files with the exact content of a deleted one
+	Renamed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Deleted:
+		return "deleted"
+	case Renamed:
+		return "renamed"
+	default:
+		return "modified"
+	}
+}
+
+// FileChange is a changed file in the worktree. From is the old path of
+// renamed files.
+type FileChange struct {
+	Path string
+	From string
+	Kind ChangeKind
+}
+
+// FileChanges returns the changed files in the worktree that are not
+// ignored, sorted by path.
+// Status reports a rename as a deleted and an added file, they are paired
+// back up when the added file holds exactly what the deleted one did.
+func (r Repository) FileChanges() ([]FileChange, error) {
+	if r.repo == nil {
+		return nil, errors.New("no repository opened")
+	}
+
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := r.worktreeRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FileChange
+	for file, fileStatus := range status {
+		if fileStatus.Worktree == git.Unmodified && fileStatus.Staging == git.Unmodified {
+			continue
+		}
+		if r.Ignored(file) {
+			continue
+		}
+
+		change := FileChange{Path: file, Kind: Modified}
+		_, err := os.Lstat(filepath.Join(root, filepath.FromSlash(file)))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			change.Kind = Deleted
+		case err != nil:
+			return nil, err
+		case fileStatus.Worktree == git.Untracked || fileStatus.Staging == git.Added:
+			change.Kind = Added
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return r.pairRenames(root, changes)
+}
+
+// pairRenames turns each deleted file whose content shows up again in an
+// added file, in the index or the worktree, into a single rename
+func (r Repository) pairRenames(root string, changes []FileChange) ([]FileChange, error) {
+	deleted := map[plumbing.Hash][]string{}
+	for _, change := range changes {
+		if change.Kind != Deleted {
+			continue
+		}
+		hash, err := r.trackedBlob(change.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !hash.IsZero() {
+			deleted[hash] = append(deleted[hash], change.Path)
+		}
+	}
+	if len(deleted) == 0 {
+		return changes, nil
+	}
+
+	renamed := map[string]bool{}
+	for i, change := range changes {
+		if change.Kind != Added {
+			continue
+		}
+
+		// A file moved with git mv keeps its blob in the index even when it
+		// was edited afterwards
+		var hashes []plumbing.Hash
+		if staged, err := r.trackedBlob(change.Path); err == nil && !staged.IsZero() {
+			hashes = append(hashes, staged)
+		}
+		if content, err := utils.ReadFileOrLink(filepath.Join(root, filepath.FromSlash(change.Path))); err == nil {
+			hashes = append(hashes, plumbing.ComputeHash(plumbing.BlobObject, content))
+		}
+
+		for _, hash := range hashes {
+			if candidates := deleted[hash]; len(candidates) > 0 {
+				changes[i].Kind = Renamed
+				changes[i].From = candidates[0]
+				renamed[candidates[0]] = true
+				deleted[hash] = candidates[1:]
+				break
+			}
+		}
+	}
+
+	paired := changes[:0]
+	for _, change := range changes {
+		if change.Kind == Deleted && renamed[change.Path] {
+			continue
+		}
+		paired = append(paired, change)
+	}
+	return paired, nil
+}
+
+// trackedBlob returns the blob git knows for file, from the index or, for
+// files whose removal was staged, from HEAD. It returns plumbing.ZeroHash for
+// files it does not know.
+func (r Repository) trackedBlob(file string) (plumbing.Hash, error) {
+	index, err := r.repo.Storer.Index()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if entry, err := index.Entry(file); err == nil {
+		return entry.Hash, nil
+	}
+
+	head, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	entry, err := r.findTreeEntry(commit.TreeHash, file)
+	if err != nil || entry == nil {
+		return plumbing.ZeroHash, err
+	}
+	return entry.Hash, nil
+}
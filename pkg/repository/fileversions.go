@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Version is a saved state of a file on its branch
+type Version struct {
+	Hash    string    `json:"hash"`
+	Path    string    `json:"path"`
+	Label   string    `json:"version"`
+	When    time.Time `json:"timestamp"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Size    int64     `json:"size"`
+	Message string    `json:"message"`
+	// RenamedFrom is the previous path of the file for its first version
+	// after a rename
+	RenamedFrom string `json:"renamedFrom,omitempty"`
+
+	blob plumbing.Hash
+	mode filemode.FileMode
+}
+
+// ErrNoVersions is returned when a file has never been saved
+var ErrNoVersions = errors.New("no saved versions")
+
+// FileVersions returns every saved version of file, newest first, following
+// the file back across renames
+func (r Repository) FileVersions(file string) ([]Version, error) {
+	file = filepath.ToSlash(filepath.Clean(file))
+
+	var versions []Version
+	err := r.walkVersions(file, func(v Version) (bool, error) {
+		size, err := r.entrySize(v.blob, v.mode)
+		if err != nil {
+			return false, err
+		}
+		v.Size = size
+		versions = append(versions, v)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%s: %w", file, ErrNoVersions)
+	}
+	return versions, nil
+}
+
+// walkVersions calls fn with the versions of file, newest first and
+// without their size, until fn returns false
+func (r Repository) walkVersions(file string, fn func(Version) (bool, error)) error {
+	if r.repo == nil {
+		return errors.New("no repository opened")
+	}
+
+	refName, err := r.FileRefName(file)
+	if err != nil {
+		return err
+	}
+
+	_, commit, err := r.refTip(refName)
+	if err != nil {
+		return err
+	}
+
+	for commit != nil {
+		entry, err := r.findTreeEntry(commit.TreeHash, file)
+		if err != nil {
+			return err
+		}
+
+		var parent *object.Commit
+		if commit.NumParents() > 0 {
+			parent, err = commit.Parent(0)
+			if err != nil {
+				return err
+			}
+		}
+
+		renamedFrom, renamed := commitTrailer(commit.Message, renamedFromTrailer)
+		renamed = renamed && commit.NumParents() > 1
+
+		// Only commits that changed the file are versions of it, or that
+		// moved it here
+		if entry != nil && (renamed || !r.sameEntry(parent, file, entry)) {
+			v := Version{
+				Hash:    commit.Hash.String(),
+				Path:    file,
+				Label:   versionLabel(file, commit.Message),
+				When:    commit.Author.When,
+				Author:  commit.Author.Name,
+				Email:   commit.Author.Email,
+				Message: commitSubject(commit.Message),
+				blob:    entry.Hash,
+				mode:    entry.Mode,
+			}
+			if renamed {
+				v.RenamedFrom = renamedFrom
+			}
+			more, err := fn(v)
+			if err != nil || !more {
+				return err
+			}
+		}
+
+		// The older versions are on the branch of the old path, behind the
+		// tombstone linked as second parent
+		if renamed {
+			parent, err = commit.Parent(1)
+			if err != nil {
+				return err
+			}
+			file = renamedFrom
+		}
+
+		commit = parent
+	}
+	return nil
+}
+
+// sameEntry reports whether commit holds exactly entry at file
+func (r Repository) sameEntry(commit *object.Commit, file string, entry *object.TreeEntry) bool {
+	if commit == nil {
+		return false
+	}
+
+	previous, err := r.findTreeEntry(commit.TreeHash, file)
+	if err != nil || previous == nil {
+		return false
+	}
+
+	return previous.Hash == entry.Hash && previous.Mode == entry.Mode
+}
@@ -35,6 +35,10 @@ func (r *Repository) GetRepoRoot() (string, error) {
 
 // ConcatenateSubmodulePath concatenates the submodule path with the repository root
 func (r *Repository) IntegrationSubmodulePath() (string, error) {
+	if r.submodulePath == "" {
+		return "", errors.New("no integration submodule path configured")
+	}
+
 	repoRoot, err := r.GetRepoRoot()
 	if err != nil {
 		return "", err
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// gitDir returns the directory holding the repository's objects and refs,
+// which for a submodule is inside the .git directory of its parent
+func (r Repository) gitDir() (string, error) {
+	if r.repo == nil {
+		return "", errors.New("no repository opened")
+	}
+
+	storage, ok := r.repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return "", errors.New("repository is not stored on disk")
+	}
+
+	return storage.Filesystem().Root(), nil
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"path/filepath"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/ignore"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// SetIgnore sets the rules for files that are never versioned, usually
+// loaded from the project
+func (r *Repository) SetIgnore(matcher *ignore.Matcher) {
+	r.ignore = matcher
+}
+
+// Ignored reports whether file, relative to the repository root, is never
+// versioned. Temporary files left by an interrupted copy never are either.
+func (r Repository) Ignored(file string) bool {
+	return utils.IsTempFile(file) || r.ignore.Match(filepath.ToSlash(file), false)
+}
+
+// IgnoreMatcher returns the rules set with SetIgnore
+func (r Repository) IgnoreMatcher() *ignore.Matcher {
+	return r.ignore
+}
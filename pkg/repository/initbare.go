@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// InitBareIntegration creates a bare integration repository at path with a
+// first commit on main, since git cannot add an empty repository as a
+// submodule
+func InitBareIntegration(path string) error {
+	repo, err := git.PlainInit(path, true)
+	if err != nil {
+		return err
+	}
+	r := Repository{repo: repo}
+
+	readme, err := r.storeBlob([]byte("# Versionctrls integration\n"))
+	if err != nil {
+		return err
+	}
+
+	tree, err := r.storeTree([]object.TreeEntry{{Name: "README.md", Mode: filemode.Regular, Hash: readme}})
+	if err != nil {
+		return err
+	}
+
+	commit, err := r.storeCommit(tree, nil, "Add README.md")
+	if err != nil {
+		return err
+	}
+
+	main := plumbing.NewBranchReferenceName("main")
+	if err := r.advanceRef(main, commit, nil); err != nil {
+		return err
+	}
+	return repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, main))
+}
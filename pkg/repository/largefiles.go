@@ -0,0 +1,477 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/renatonmag/versionctrls-cli/pkg/chunker"
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/encryption"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// ErrFileTooLarge is returned for files over their size limit whose
+// strategy is to skip them
+var ErrFileTooLarge = errors.New("file is over the size limit")
+
+// chunkName is how the chunks of a large file are named in its tree
+var chunkName = regexp.MustCompile(`^[0-9]{6}$`)
+
+const (
+	// chunkManifest is the entry of a chunk tree that lists its chunks
+	chunkManifest       = ".versionctrls-chunks"
+	chunkManifestHeader = "versionctrls chunks v1"
+
+	lfsVersion    = "version https://git-lfs.github.com/spec/v1"
+	lfsObjectsDir = "lfs/objects"
+	// maxPointerSize is comfortably more than any LFS pointer file
+	maxPointerSize = 512
+)
+
+// SetLargeFiles sets the size limits and the strategy for files over them,
+// usually from the project configuration
+func (r *Repository) SetLargeFiles(largeFiles config.LargeFiles) {
+	r.largeFiles = largeFiles
+}
+
+// CheckSize reports whether a file of the given size can be versioned, and
+// a warning to show when it cannot
+func (r Repository) CheckSize(file string, size int64) (bool, string) {
+	limit, strategy := r.largeFiles.Policy(filepath.ToSlash(file))
+	if size <= limit || strategy != config.StrategySkip {
+		return true, ""
+	}
+	return false, fmt.Sprintf("Warning: skipping %s, %s is over the %s limit. Raise large_files.max_size or pick another strategy in %s to version it.",
+		file, utils.FormatSize(size), utils.FormatSize(limit), config.ProjectFile)
+}
+
+// storeContent stores content for path and returns the tree entry that
+// records it. Content is encrypted when encryption is on. Content over the
+// size limit of path is stored as an LFS pointer or as a tree of chunks
+// when the strategy says so. previous is the entry of the last version of
+// path, if any, whose unchanged chunks are reused.
+func (r Repository) storeContent(path string, content []byte, mode filemode.FileMode, previous *object.TreeEntry) (object.TreeEntry, error) {
+	if mode == filemode.Symlink {
+		hash, err := r.storeBlob(content)
+		return object.TreeEntry{Mode: mode, Hash: hash}, err
+	}
+
+	limit, strategy := r.largeFiles.Policy(path)
+	large := int64(len(content)) > limit
+	if large && strategy == config.StrategyChunk {
+		hash, err := r.storeChunks(path, content, previous)
+		return object.TreeEntry{Mode: filemode.Dir, Hash: hash}, err
+	}
+	if large && strategy != config.StrategyLFS {
+		return object.TreeEntry{}, fmt.Errorf("%s, %s: %w", path, utils.FormatSize(int64(len(content))), ErrFileTooLarge)
+	}
+
+	content, err := r.keys.Encrypt(content)
+	if err != nil {
+		return object.TreeEntry{}, fmt.Errorf("could not encrypt %s: %w", path, err)
+	}
+
+	if large {
+		pointer, err := r.storeLFSObject(content)
+		if err != nil {
+			return object.TreeEntry{}, err
+		}
+		content = pointer
+	}
+	hash, err := r.storeBlob(content)
+	return object.TreeEntry{Mode: mode, Hash: hash}, err
+}
+
+// chunkList is what the manifest of a chunk tree records about the plain
+// content of each chunk
+type chunkList struct {
+	// recipients is the keyring the chunks were encrypted for, see
+	// encryption.Keyring.Recipients
+	recipients string
+	sums       []string
+	sizes      []int64
+}
+
+// storeChunks stores content as a tree of numbered chunk blobs, which
+// readEntry joins back together. Chunks are cut from the plain content and
+// encrypted one by one. Encrypting gives different bytes every time, so
+// chunks whose plain content is in the previous version are reused from
+// it, as long as they were encrypted for the same recipients.
+func (r Repository) storeChunks(path string, content []byte, previous *object.TreeEntry) (plumbing.Hash, error) {
+	recipients, err := r.keys.Recipients()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not encrypt %s: %w", path, err)
+	}
+
+	reuse := map[string]plumbing.Hash{}
+	if previous != nil && previous.Mode == filemode.Dir {
+		// A previous version that cannot be read is simply not reused
+		list, chunks, err := r.readChunkList(previous.Hash)
+		if err == nil && list != nil && list.recipients == recipients {
+			for i, sum := range list.sums {
+				reuse[sum] = chunks[i].Hash
+			}
+		}
+	}
+
+	manifest := fmt.Sprintf("%s\nrecipients %s\n", chunkManifestHeader, recipients)
+	var entries []object.TreeEntry
+	for i, chunk := range chunker.Split(content) {
+		digest := sha256.Sum256(chunk)
+		sum := hex.EncodeToString(digest[:])
+
+		hash, ok := reuse[sum]
+		if !ok {
+			encrypted, err := r.keys.Encrypt(chunk)
+			if err != nil {
+				return plumbing.ZeroHash, fmt.Errorf("could not encrypt %s: %w", path, err)
+			}
+			hash, err = r.storeBlob(encrypted)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+		}
+		entries = append(entries, object.TreeEntry{Name: fmt.Sprintf("%06d", i), Mode: filemode.Regular, Hash: hash})
+		manifest += fmt.Sprintf("%s %d\n", sum, len(chunk))
+	}
+
+	encrypted, err := r.keys.Encrypt([]byte(manifest))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not encrypt %s: %w", path, err)
+	}
+	hash, err := r.storeBlob(encrypted)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	entries = append(entries, object.TreeEntry{Name: chunkManifest, Mode: filemode.Regular, Hash: hash})
+	return r.storeTree(entries)
+}
+
+// readChunkList returns the manifest of the chunk tree with the given hash
+// and its chunks in order. The list is nil for chunk trees stored before
+// manifests, which hold encrypted content cut into chunks.
+func (r Repository) readChunkList(hash plumbing.Hash) (*chunkList, []object.TreeEntry, error) {
+	entries, err := r.treeEntries(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var chunks []object.TreeEntry
+	var manifest *object.TreeEntry
+	for i, entry := range entries {
+		if entry.Name == chunkManifest {
+			manifest = &entries[i]
+			continue
+		}
+		chunks = append(chunks, entry)
+	}
+	if manifest == nil {
+		return nil, chunks, nil
+	}
+
+	stored, err := r.readBlob(manifest.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := r.keys.Decrypt(stored)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) < 2 || lines[0] != chunkManifestHeader || !strings.HasPrefix(lines[1], "recipients ") {
+		return nil, nil, fmt.Errorf("chunk manifest %s is not valid", manifest.Hash.String()[:7])
+	}
+	list := &chunkList{recipients: strings.TrimPrefix(lines[1], "recipients ")}
+	for _, line := range lines[2:] {
+		sum, sizeField, _ := strings.Cut(line, " ")
+		size, err := strconv.ParseInt(sizeField, 10, 64)
+		if err != nil || len(sum) != 2*sha256.Size {
+			return nil, nil, fmt.Errorf("chunk manifest %s is not valid", manifest.Hash.String()[:7])
+		}
+		list.sums = append(list.sums, sum)
+		list.sizes = append(list.sizes, size)
+	}
+	if len(list.sums) != len(chunks) {
+		return nil, nil, fmt.Errorf("chunk manifest %s lists %d chunks, the tree has %d", manifest.Hash.String()[:7], len(list.sums), len(chunks))
+	}
+	return list, chunks, nil
+}
+
+// readChunks returns the plain content of the chunk tree with the given
+// hash
+func (r Repository) readChunks(hash plumbing.Hash) ([]byte, error) {
+	list, chunks, err := r.readChunkList(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	for _, entry := range chunks {
+		chunk, err := r.readBlob(entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if list != nil {
+			chunk, err = r.keys.Decrypt(chunk)
+			if err != nil {
+				return nil, err
+			}
+		}
+		content = append(content, chunk...)
+	}
+	if list == nil {
+		return r.keys.Decrypt(content)
+	}
+	return content, nil
+}
+
+// isChunks reports whether the tree with the given hash holds the chunks of
+// a large file rather than a directory, which the chunk manifest marks.
+// Chunk trees stored before manifests are only told apart where the path
+// of the file is known.
+func (r Repository) isChunks(hash plumbing.Hash) bool {
+	entries, err := r.treeEntries(hash)
+	if err != nil {
+		return false
+	}
+
+	marked := false
+	for _, entry := range entries {
+		if entry.Mode != filemode.Regular {
+			return false
+		}
+		if entry.Name == chunkManifest {
+			marked = true
+		} else if !chunkName.MatchString(entry.Name) {
+			return false
+		}
+	}
+	return marked
+}
+
+// storeLFSObject writes content to the LFS object store, where git lfs push
+// finds it, and returns the pointer file that stands for it
+func (r Repository) storeLFSObject(content []byte) ([]byte, error) {
+	sum := sha256.Sum256(content)
+	oid := hex.EncodeToString(sum[:])
+
+	path, err := r.lfsObjectPath(oid)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := utils.WriteFileAtomic(path, content, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	return []byte(fmt.Sprintf("%s\noid sha256:%s\nsize %d\n", lfsVersion, oid, len(content))), nil
+}
+
+// lfsObjectPath returns where git lfs keeps the object oid
+func (r Repository) lfsObjectPath(oid string) (string, error) {
+	dir, err := r.gitDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(lfsObjectsDir), oid[0:2], oid[2:4], oid), nil
+}
+
+// parseLFSPointer returns the object id and size of an LFS pointer file
+func parseLFSPointer(content []byte) (string, int64, bool) {
+	if len(content) > maxPointerSize || !bytes.HasPrefix(content, []byte(lfsVersion+"\n")) {
+		return "", 0, false
+	}
+
+	oid, size := "", int64(-1)
+	for _, line := range strings.Split(string(content), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "oid":
+			oid = strings.TrimPrefix(value, "sha256:")
+		case "size":
+			size, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	if len(oid) != 2*sha256.Size || size < 0 {
+		return "", 0, false
+	}
+	return oid, size, true
+}
+
+// readEntry returns the content recorded by a tree entry, joining chunks,
+// resolving LFS pointers and decrypting
+func (r Repository) readEntry(hash plumbing.Hash, mode filemode.FileMode) ([]byte, error) {
+	if mode == filemode.Dir {
+		return r.readChunks(hash)
+	}
+
+	content, err := r.readStored(hash, mode)
+	if err != nil || mode == filemode.Symlink {
+		return content, err
+	}
+	return r.keys.Decrypt(content)
+}
+
+// readStored returns the content recorded by a blob entry as it was
+// stored, resolving LFS pointers
+func (r Repository) readStored(hash plumbing.Hash, mode filemode.FileMode) ([]byte, error) {
+	content, err := r.readBlob(hash)
+	if err != nil || mode == filemode.Symlink {
+		return content, err
+	}
+
+	oid, _, ok := parseLFSPointer(content)
+	if !ok {
+		return content, nil
+	}
+
+	path, err := r.lfsObjectPath(oid)
+	if err != nil {
+		return nil, err
+	}
+	object, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("LFS object %s is not on this machine, fetch it with git lfs fetch in the integration repository", oid[:12])
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sum := sha256.Sum256(object); hex.EncodeToString(sum[:]) != oid {
+		return nil, fmt.Errorf("LFS object %s is corrupt", oid[:12])
+	}
+	return object, nil
+}
+
+// entrySize returns the size of the content recorded by a tree entry, the
+// plain size for encrypted content
+func (r Repository) entrySize(hash plumbing.Hash, mode filemode.FileMode) (int64, error) {
+	if mode == filemode.Dir {
+		list, _, err := r.readChunkList(hash)
+		if err != nil {
+			// Without the keys to the manifest the stored size is all there is
+			return r.storedSize(hash, mode)
+		}
+		if list != nil {
+			var size int64
+			for _, n := range list.sizes {
+				size += n
+			}
+			return size, nil
+		}
+	}
+
+	size, err := r.storedSize(hash, mode)
+	if err != nil || r.keys == nil || mode == filemode.Symlink {
+		return size, err
+	}
+
+	prefix, err := r.storedPrefix(hash, mode)
+	if err != nil {
+		return 0, err
+	}
+	if plain, ok := encryption.PlainSize(prefix, size); ok {
+		return plain, nil
+	}
+	return size, nil
+}
+
+// storedSize returns the size of the content recorded by a tree entry as
+// it was stored
+func (r Repository) storedSize(hash plumbing.Hash, mode filemode.FileMode) (int64, error) {
+	if mode == filemode.Dir {
+		entries, err := r.treeEntries(hash)
+		if err != nil {
+			return 0, err
+		}
+
+		var size int64
+		for _, entry := range entries {
+			if entry.Name == chunkManifest {
+				continue
+			}
+			n, err := r.repo.Storer.EncodedObjectSize(entry.Hash)
+			if err != nil {
+				return 0, err
+			}
+			size += n
+		}
+		return size, nil
+	}
+
+	size, err := r.repo.Storer.EncodedObjectSize(hash)
+	if err != nil || size > maxPointerSize || mode == filemode.Symlink {
+		return size, err
+	}
+
+	// Small blobs may be LFS pointers
+	content, err := r.readBlob(hash)
+	if err != nil {
+		return 0, err
+	}
+	if _, objectSize, ok := parseLFSPointer(content); ok {
+		return objectSize, nil
+	}
+	return size, nil
+}
+
+// LFSObjects returns the ids of the objects in the LFS object store
+func (r Repository) LFSObjects() ([]string, error) {
+	dir, err := r.gitDir()
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(lfsObjectsDir), "??", "??", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var oids []string
+	for _, path := range paths {
+		if oid := filepath.Base(path); len(oid) == 2*sha256.Size {
+			oids = append(oids, oid)
+		}
+	}
+	return oids, nil
+}
+
+// PushLFSObjects uploads the LFS objects to remoteName with git lfs, which
+// skips the ones the server already has. It returns how many objects were
+// offered.
+func (r Repository) PushLFSObjects(remoteName string) (int, error) {
+	oids, err := r.LFSObjects()
+	if err != nil || len(oids) == 0 {
+		return 0, err
+	}
+
+	root, err := r.worktreeRoot()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := r.RunGitCommand("-C", root, "lfs", "version"); err != nil {
+		return 0, errors.New("git lfs is not installed, large files stored as LFS objects stay on this machine")
+	}
+
+	args := append([]string{"-C", root, "lfs", "push", "--object-id", remoteName}, oids...)
+	if _, err := r.RunGitCommand(args...); err != nil {
+		return 0, err
+	}
+	return len(oids), nil
+}
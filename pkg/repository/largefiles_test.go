@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/encryption"
+)
+
+func TestEncryptedChunksAreShared(t *testing.T) {
+	testUser(t)
+	r, dir := newClone(t, newRemote(t))
+	r.SetLargeFiles(config.LargeFiles{MaxSize: "1KiB", Strategy: config.StrategyChunk})
+	keys, err := encryption.Load(config.Encryption{Method: config.EncryptPassphrase, Salt: "test"}, func() (string, error) {
+		return "hunter2", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetEncryption(keys)
+
+	content := make([]byte, 4<<20)
+	rand.New(rand.NewSource(1)).Read(content)
+	edited := append([]byte{}, content...)
+	copy(edited[len(edited)-100:], "an edit near the end")
+
+	save(t, r, dir, "data.bin", string(content))
+	save(t, r, dir, "data.bin", string(edited))
+
+	versions, err := r.FileVersions("data.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("got %d versions, want 2", len(versions))
+	}
+
+	chunks := map[plumbing.Hash]bool{}
+	for i, want := range [][]byte{edited, content} {
+		v := versions[i]
+		if v.mode != filemode.Dir {
+			t.Fatalf("version %d is stored with mode %s, want chunks", i, v.mode)
+		}
+		if v.Size != int64(len(want)) {
+			t.Errorf("version %d has size %d, want %d", i, v.Size, len(want))
+		}
+		got, err := r.ReadVersion(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("version %d does not read back as saved", i)
+		}
+
+		_, entries, err := r.readChunkList(v.blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			chunks[entry.Hash] = true
+		}
+	}
+
+	// Only the chunk with the edit is stored again
+	_, entries, _ := r.readChunkList(versions[1].blob)
+	if len(entries) < 4 || len(chunks) != len(entries)+1 {
+		t.Errorf("the versions have %d chunks between them, the first has %d", len(chunks), len(entries))
+	}
+	for hash := range chunks {
+		blob, err := r.readBlob(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !encryption.IsEncrypted(blob) {
+			t.Errorf("chunk %s is stored plain", hash)
+		}
+	}
+
+	// A new passphrase means new keys, and chunks encrypted with the old
+	// ones are not reused
+	keys, _ = encryption.Load(config.Encryption{Method: config.EncryptPassphrase, Salt: "other"}, func() (string, error) {
+		return "hunter2", nil
+	})
+	r.SetEncryption(keys)
+	previous := &object.TreeEntry{Mode: versions[0].mode, Hash: versions[0].blob}
+	entry, err := r.storeContent("data.bin", content, filemode.Regular, previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rewritten, err := r.readChunkList(entry.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range rewritten {
+		if chunks[e.Hash] {
+			t.Errorf("chunk %s was reused for other recipients", e.Hash)
+		}
+	}
+}
+
+func TestChunkTreesAreMarked(t *testing.T) {
+	testUser(t)
+	r, _ := newClone(t, newRemote(t))
+	r.SetLargeFiles(config.LargeFiles{MaxSize: "1KiB", Strategy: config.StrategyChunk})
+
+	content := make([]byte, 1<<20)
+	rand.New(rand.NewSource(2)).Read(content)
+
+	tree := plumbing.ZeroHash
+	for path, content := range map[string][]byte{
+		"data.bin":          content,
+		"migrations/000001": []byte("create table a;\n"),
+		"migrations/000002": []byte("create table b;\n"),
+	} {
+		entry, err := r.storeContent(path, content, filemode.Regular, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err = r.updateTree(tree, path, &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := r.treeFiles(tree, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"data.bin", "migrations/000001", "migrations/000002"}
+	if len(files) != len(want) {
+		t.Fatalf("treeFiles = %v, want %v", files, want)
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Errorf("treeFiles = %v, want %v", files, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// RefMigration describes a snapshot branch moved into the private namespace
+type RefMigration struct {
+	Branch string
+	Ref    plumbing.ReferenceName
+	Path   string
+	Err    error
+}
+
+// MigrateSnapshotBranches moves every per-file snapshot branch out of
+// refs/heads and into FileRefPrefix, then stores new snapshots there too.
+// Branches that fail to move are reported and left in place.
+func (r Repository) MigrateSnapshotBranches() ([]RefMigration, error) {
+	if r.repo == nil {
+		return nil, errors.New("no repository opened")
+	}
+
+	branches, err := r.repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+
+	head, _ := r.repo.Reference(plumbing.HEAD, false)
+
+	var candidates []*plumbing.Reference
+	err = branches.ForEach(func(ref *plumbing.Reference) error {
+		if head == nil || head.Target() != ref.Name() {
+			candidates = append(candidates, ref)
+		}
+		return nil
+	})
+	branches.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []RefMigration
+	for _, ref := range candidates {
+		path, err := r.RefPath(ref.Name())
+		if err != nil {
+			// Not a snapshot branch
+			continue
+		}
+
+		migration := RefMigration{
+			Branch: ref.Name().Short(),
+			Ref:    plumbing.ReferenceName(FileRefPrefix + FileBranchName(path)),
+			Path:   path,
+		}
+		migration.Err = r.moveRef(ref, migration.Ref)
+		migrations = append(migrations, migration)
+	}
+
+	return migrations, r.EnableRefNamespace()
+}
+
+// moveRef points newName at the commit of ref and deletes ref
+func (r Repository) moveRef(ref *plumbing.Reference, newName plumbing.ReferenceName) error {
+	existing, err := r.repo.Reference(newName, false)
+	switch {
+	case err == plumbing.ErrReferenceNotFound:
+		err = r.advanceRef(newName, ref.Hash(), nil)
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case existing.Hash() != ref.Hash():
+		return fmt.Errorf("%s already exists and points elsewhere", strings.TrimPrefix(newName.String(), "refs/"))
+	}
+
+	return r.repo.Storer.RemoveReference(ref.Name())
+}
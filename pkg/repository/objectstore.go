@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// storeBlob writes content to the object storer and returns the blob hash
+func (r Repository) storeBlob(content []byte) (plumbing.Hash, error) {
+	obj := r.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.repo.Storer.SetEncodedObject(obj)
+}
+
+// readBlob returns the content of the blob with the given hash
+func (r Repository) readBlob(hash plumbing.Hash) ([]byte, error) {
+	blob, err := r.repo.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// storeTree sorts the entries in git order and writes them as a tree object
+func (r Repository) storeTree(entries []object.TreeEntry) (plumbing.Hash, error) {
+	sort.Slice(entries, func(i, j int) bool {
+		return treeSortKey(entries[i]) < treeSortKey(entries[j])
+	})
+
+	tree := &object.Tree{Entries: entries}
+	obj := r.repo.Storer.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.repo.Storer.SetEncodedObject(obj)
+}
+
+// treeSortKey returns the name git uses to order a tree entry, where
+// directories sort as if they had a trailing slash
+func treeSortKey(e object.TreeEntry) string {
+	if e.Mode == filemode.Dir {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
+// treeEntries returns a copy of the entries of the tree with the given hash,
+// treating plumbing.ZeroHash as the empty tree
+func (r Repository) treeEntries(hash plumbing.Hash) ([]object.TreeEntry, error) {
+	if hash.IsZero() {
+		return nil, nil
+	}
+
+	tree, err := r.repo.TreeObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]object.TreeEntry, len(tree.Entries))
+	copy(entries, tree.Entries)
+	return entries, nil
+}
+
+// updateTree returns the hash of a tree equal to base with the entry at path
+// replaced by entry. A nil entry removes path, pruning directories left empty.
+func (r Repository) updateTree(base plumbing.Hash, path string, entry *object.TreeEntry) (plumbing.Hash, error) {
+	if path == "" {
+		return plumbing.ZeroHash, errors.New("empty path")
+	}
+
+	entries, err := r.treeEntries(base)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	name, rest, isDir := strings.Cut(path, "/")
+
+	index := -1
+	for i, e := range entries {
+		if e.Name == name {
+			index = i
+			break
+		}
+	}
+
+	var updated *object.TreeEntry
+	if isDir {
+		subtree := plumbing.ZeroHash
+		if index >= 0 && entries[index].Mode == filemode.Dir {
+			subtree = entries[index].Hash
+		}
+
+		hash, err := r.updateTree(subtree, rest, entry)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if !hash.IsZero() {
+			updated = &object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash}
+		}
+	} else if entry != nil {
+		updated = &object.TreeEntry{Name: name, Mode: entry.Mode, Hash: entry.Hash}
+	}
+
+	switch {
+	case updated != nil && index >= 0:
+		entries[index] = *updated
+	case updated != nil:
+		entries = append(entries, *updated)
+	case index >= 0:
+		entries = append(entries[:index], entries[index+1:]...)
+	}
+
+	if len(entries) == 0 {
+		return plumbing.ZeroHash, nil
+	}
+
+	return r.storeTree(entries)
+}
+
+// findTreeEntry looks up path in the tree with the given hash, returning nil
+// when the tree or the entry does not exist
+func (r Repository) findTreeEntry(tree plumbing.Hash, path string) (*object.TreeEntry, error) {
+	if tree.IsZero() {
+		return nil, nil
+	}
+
+	t, err := r.repo.TreeObject(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := t.FindEntry(path)
+	if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// storeCommit writes a commit for tree with the given parents and message,
+// signed with the global Git user
+func (r Repository) storeCommit(tree plumbing.Hash, parents []plumbing.Hash, message string) (plumbing.Hash, error) {
+	if tree.IsZero() {
+		// An empty tree still needs to exist as an object to be committed
+		hash, err := r.storeTree(nil)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		tree = hash
+	}
+
+	name, email, err := r.GetGitUserInfo()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	signature := object.Signature{
+		Name:  name,
+		Email: email,
+		When:  time.Now(),
+	}
+	commit := &object.Commit{
+		Author:       signature,
+		Committer:    signature,
+		Message:      message,
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+
+	obj := r.repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.repo.Storer.SetEncodedObject(obj)
+}
+
+// advanceRef points refName at hash, failing if the reference was moved
+// away from old by someone else in the meantime
+func (r Repository) advanceRef(refName plumbing.ReferenceName, hash plumbing.Hash, old *plumbing.Reference) error {
+	ref := plumbing.NewHashReference(refName, hash)
+	if err := r.repo.Storer.CheckAndSetReference(ref, old); err != nil {
+		return fmt.Errorf("could not update %s: %w", refName, err)
+	}
+	return nil
+}
+
+// refTip returns the reference and its commit for refName, or nils when the
+// reference does not exist yet
+func (r Repository) refTip(refName plumbing.ReferenceName) (*plumbing.Reference, *object.Commit, error) {
+	ref, err := r.repo.Reference(refName, true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commit, err := r.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ref, commit, nil
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+const (
+	// outboxFile lives in the git dir of the integration repository, so it is
+	// never committed or pushed itself
+	outboxFile = "versionctrls/outbox.json"
+
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// outboxMu keeps saves that queue references and pushes that settle them
+// from rewriting the outbox at the same time
+var outboxMu sync.Mutex
+
+// OutboxEntry is a snapshot reference holding versions that were not pushed yet
+type OutboxEntry struct {
+	Ref         plumbing.ReferenceName `json:"ref"`
+	Path        string                 `json:"path"`
+	Versions    int                    `json:"versions"`
+	Queued      time.Time              `json:"queued"`
+	Attempts    int                    `json:"attempts,omitempty"`
+	NextAttempt time.Time              `json:"next_attempt,omitempty"`
+	LastError   string                 `json:"last_error,omitempty"`
+}
+
+// Due reports whether the entry may be retried at now
+func (e OutboxEntry) Due(now time.Time) bool {
+	return !now.Before(e.NextAttempt)
+}
+
+// Outbox returns the references waiting to be pushed, oldest first
+func (r Repository) Outbox() ([]OutboxEntry, error) {
+	outboxMu.Lock()
+	entries, err := r.readOutbox()
+	outboxMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Queued.Before(entries[j].Queued)
+	})
+	return entries, nil
+}
+
+// QueueRef records that refName, holding the versions of path, got a version
+// that still has to be pushed
+func (r Repository) QueueRef(refName plumbing.ReferenceName, path string) error {
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+
+	entries, err := r.readOutbox()
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].Ref == refName {
+			entries[i].Versions++
+			return r.writeOutbox(entries)
+		}
+	}
+
+	entries = append(entries, OutboxEntry{
+		Ref:      refName,
+		Path:     path,
+		Versions: 1,
+		Queued:   time.Now(),
+	})
+	return r.writeOutbox(entries)
+}
+
+// renameQueuedRef moves the queued versions of from over to to
+func (r Repository) renameQueuedRef(from, to plumbing.ReferenceName) error {
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+
+	entries, err := r.readOutbox()
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].Ref == from {
+			entries[i].Ref = to
+			return r.writeOutbox(entries)
+		}
+	}
+	return nil
+}
+
+// RetryOutbox pushes the queued references to remoteName. Unless force is
+// set, references whose last attempt failed are left alone until their
+// backoff delay is over.
+func (r Repository) RetryOutbox(remoteName string, auth transport.AuthMethod, force bool) ([]RefSyncResult, error) {
+	outboxMu.Lock()
+	entries, err := r.readOutbox()
+	outboxMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var refs []plumbing.ReferenceName
+	for _, entry := range entries {
+		if force || entry.Due(now) {
+			refs = append(refs, entry.Ref)
+		}
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	return r.PushRefs(remoteName, auth, refs)
+}
+
+// settleOutbox drops the references that were pushed from the outbox and
+// schedules the next attempt for those that failed. References that got
+// another version while they were pushed stay queued.
+func (r Repository) settleOutbox(results []RefSyncResult) error {
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+
+	entries, err := r.readOutbox()
+	if err != nil {
+		return err
+	}
+
+	outcome := map[plumbing.ReferenceName]RefSyncResult{}
+	for _, result := range results {
+		outcome[result.Ref] = result
+	}
+
+	now := time.Now()
+	kept := entries[:0]
+	for _, entry := range entries {
+		result, attempted := outcome[entry.Ref]
+		switch {
+		case !attempted:
+		case result.Err == nil:
+			if local, err := r.repo.Reference(entry.Ref, true); err == nil && local.Hash() == result.pushed {
+				continue
+			}
+		default:
+			entry.Attempts++
+			entry.NextAttempt = now.Add(retryDelay(entry.Attempts))
+			entry.LastError = result.Err.Error()
+		}
+		kept = append(kept, entry)
+	}
+
+	return r.writeOutbox(kept)
+}
+
+// retryDelay doubles the wait after every failed attempt, up to retryMaxDelay
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// outboxPath returns where the outbox of the opened repository is kept
+func (r Repository) outboxPath() (string, error) {
+	dir, err := r.gitDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(outboxFile)), nil
+}
+
+func (r Repository) readOutbox() ([]OutboxEntry, error) {
+	path, err := r.outboxPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []OutboxEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+func (r Repository) writeOutbox(entries []OutboxEntry) error {
+	path, err := r.outboxPath()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		err := os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, data, 0644)
+}
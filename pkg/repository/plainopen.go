@@ -12,6 +12,7 @@ func (r *Repository) PlainOpen(path string) error {
 		return errors.New("repository does not exist")
 	}
 	r.repo = repo
+	r.layout = &refLayout{}
 
 	return nil
 }
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// MirrorChange applies change to the copy of the file in the submodule,
+// reporting whether the copy changed. Deleted files are removed and renamed
+// files moved.
+func (r Repository) MirrorChange(change FileChange) (bool, error) {
+	switch change.Kind {
+	case Deleted:
+		return r.RemoveFileFromSubmodule(change.Path)
+	case Renamed:
+		if _, err := r.RemoveFileFromSubmodule(change.From); err != nil {
+			return false, err
+		}
+	}
+	return r.CopyFileToSubmodule(change.Path)
+}
+
+// PropagateChange mirrors change to the submodule and records it in
+// integration, the repository of the submodule. It returns the commit made,
+// or plumbing.ZeroHash when there was nothing to record.
+func (r Repository) PropagateChange(integration *Repository, change FileChange) (plumbing.Hash, error) {
+	copied, err := r.MirrorChange(change)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	switch {
+	case change.Kind == Deleted:
+		return integration.DeleteFile(change.Path)
+	case change.Kind == Renamed && copied:
+		return integration.RenameFile(change.From, change.Path)
+	case change.Kind == Renamed:
+		// The new path is not versioned, so as far as the integration
+		// repository is concerned the file is gone
+		return integration.DeleteFile(change.From)
+	case copied:
+		return integration.SaveFile(change.Path)
+	}
+	return plumbing.ZeroHash, nil
+}
+
+// RemoveFileFromSubmodule deletes the copy of file in the submodule along
+// with the directories it leaves empty, reporting whether there was a copy
+func (r Repository) RemoveFileFromSubmodule(file string) (bool, error) {
+	submodulePath, err := r.IntegrationSubmodulePath()
+	if err != nil {
+		return false, err
+	}
+
+	dstPath := filepath.Join(submodulePath, file)
+	err = os.Remove(dstPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("Removed %s\n", dstPath)
+
+	for dir := filepath.Dir(dstPath); dir != submodulePath && len(dir) > len(submodulePath); dir = filepath.Dir(dir) {
+		// Fails, and stops, on the first directory that is not empty
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return true, nil
+}
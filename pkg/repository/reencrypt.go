@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/renatonmag/versionctrls-cli/pkg/encryption"
+)
+
+// rewriter re-encrypts history, remembering what it already rewrote so that
+// content shared between versions and branches stays shared
+type rewriter struct {
+	r       Repository
+	old     *encryption.Keyring
+	commits map[plumbing.Hash]plumbing.Hash
+	trees   map[string]plumbing.Hash
+	blobs   map[string]object.TreeEntry
+	// chunked is the last chunk tree rewritten for each path, whose chunks
+	// the next version of the path reuses
+	chunked map[string]*object.TreeEntry
+}
+
+// ReencryptHistory rewrites every version on the per-file branches, the
+// changesets and the checkpoints, so that their content is encrypted with
+// the keyring set with SetEncryption, or stored plain when there is none.
+// old decrypts the content as it is now. Commits keep their authors, dates
+// and messages. It returns the branches that changed, which then have to be
+// pushed with force.
+func (r Repository) ReencryptHistory(old *encryption.Keyring) ([]plumbing.ReferenceName, error) {
+	refs, err := r.FileRefs()
+	if err != nil {
+		return nil, err
+	}
+	refs[ChangesetsRef] = "changesets"
+	refs[CheckpointsRef] = "checkpoints"
+
+	w := &rewriter{
+		r:       r,
+		old:     old,
+		commits: map[plumbing.Hash]plumbing.Hash{},
+		trees:   map[string]plumbing.Hash{},
+		blobs:   map[string]object.TreeEntry{},
+		chunked: map[string]*object.TreeEntry{},
+	}
+
+	// Every branch is rewritten before any moves, so a failure halfway
+	// leaves history as it was
+	updates := map[plumbing.ReferenceName]plumbing.Hash{}
+	current := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for refName := range refs {
+		ref, tip, err := r.refTip(refName)
+		if err != nil {
+			return nil, err
+		}
+		if tip == nil {
+			continue
+		}
+
+		hash, err := w.commit(tip.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("could not re-encrypt %s: %w", refs[refName], err)
+		}
+		if hash != tip.Hash {
+			updates[refName] = hash
+			current[refName] = ref
+		}
+	}
+
+	var changed []plumbing.ReferenceName
+	for refName, hash := range updates {
+		if err := r.advanceRef(refName, hash, current[refName]); err != nil {
+			return changed, err
+		}
+		changed = append(changed, refName)
+	}
+	return changed, nil
+}
+
+// commit returns the rewritten commit hash, after its parents
+func (w *rewriter) commit(hash plumbing.Hash) (plumbing.Hash, error) {
+	if rewritten, ok := w.commits[hash]; ok {
+		return rewritten, nil
+	}
+
+	commit, err := w.r.repo.CommitObject(hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	changed := false
+	parents := make([]plumbing.Hash, len(commit.ParentHashes))
+	for i, parent := range commit.ParentHashes {
+		parents[i], err = w.commit(parent)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		changed = changed || parents[i] != parent
+	}
+
+	// The files a commit saved are known by path, which tells the chunk
+	// trees stored before chunk manifests from directories
+	files := map[string]bool{}
+	for _, key := range []string{pathTrailer, savedTrailer, changedTrailer} {
+		for _, path := range commitTrailers(commit.Message, key) {
+			files[path] = true
+		}
+	}
+
+	tree, err := w.tree(commit.TreeHash, "", files)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	changed = changed || tree != commit.TreeHash
+
+	rewritten := hash
+	if changed {
+		updated := &object.Commit{
+			Author:       commit.Author,
+			Committer:    commit.Committer,
+			Message:      commit.Message,
+			TreeHash:     tree,
+			ParentHashes: parents,
+		}
+		obj := w.r.repo.Storer.NewEncodedObject()
+		if err := updated.Encode(obj); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		rewritten, err = w.r.repo.Storer.SetEncodedObject(obj)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	w.commits[hash] = rewritten
+	return rewritten, nil
+}
+
+// tree returns the rewritten hash of the tree at dir, in a commit that
+// saved files
+func (w *rewriter) tree(hash plumbing.Hash, dir string, files map[string]bool) (plumbing.Hash, error) {
+	key := dir + hash.String()
+	if rewritten, ok := w.trees[key]; ok {
+		return rewritten, nil
+	}
+
+	entries, err := w.r.treeEntries(hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	changed := false
+	for i, entry := range entries {
+		path := dir + entry.Name
+
+		var updated object.TreeEntry
+		switch {
+		case entry.Mode == filemode.Dir && !w.isFile(entry, path, files):
+			subtree, err := w.tree(entry.Hash, path+"/", files)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			updated = object.TreeEntry{Name: entry.Name, Mode: entry.Mode, Hash: subtree}
+		case entry.Mode == filemode.Dir || entry.Mode.IsFile() && entry.Mode != filemode.Symlink:
+			updated, err = w.file(entry, path)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+		default:
+			updated = entry
+		}
+
+		changed = changed || updated.Hash != entry.Hash || updated.Mode != entry.Mode
+		entries[i] = updated
+	}
+
+	rewritten := hash
+	if changed {
+		rewritten, err = w.r.storeTree(entries)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+	w.trees[key] = rewritten
+	return rewritten, nil
+}
+
+// isFile reports whether a tree entry at path holds the chunks of a file:
+// its manifest says so, the commit saved a file at path, or an earlier
+// commit did and the chunks were rewritten then
+func (w *rewriter) isFile(entry object.TreeEntry, path string, files map[string]bool) bool {
+	if _, ok := w.blobs[entry.Mode.String()+entry.Hash.String()]; ok {
+		return true
+	}
+	return files[path] || w.r.isChunks(entry.Hash)
+}
+
+// file returns the entry for the content of a file entry stored again
+// with the new keyring
+func (w *rewriter) file(entry object.TreeEntry, path string) (object.TreeEntry, error) {
+	key := entry.Mode.String() + entry.Hash.String()
+	if rewritten, ok := w.blobs[key]; ok {
+		rewritten.Name = entry.Name
+		return rewritten, nil
+	}
+
+	var plain []byte
+	if entry.Mode == filemode.Dir {
+		readable := w.r
+		readable.keys = w.old
+		chunks, err := readable.readChunks(entry.Hash)
+		if err != nil {
+			return object.TreeEntry{}, fmt.Errorf("%s: %w", path, err)
+		}
+		plain = chunks
+	} else {
+		stored, err := w.r.readStored(entry.Hash, entry.Mode)
+		if err != nil {
+			return object.TreeEntry{}, err
+		}
+		if !encryption.IsEncrypted(stored) && w.r.keys == nil {
+			// Plain already and staying plain
+			w.blobs[key] = entry
+			return entry, nil
+		}
+		plain, err = w.old.Decrypt(stored)
+		if err != nil {
+			return object.TreeEntry{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	mode := entry.Mode
+	if mode == filemode.Dir {
+		mode = filemode.Regular
+	}
+	rewritten, err := w.r.storeContent(path, plain, mode, w.chunked[path])
+	if errors.Is(err, ErrFileTooLarge) {
+		// Versions saved under an earlier size limit are kept whole
+		var encrypted []byte
+		encrypted, err = w.r.keys.Encrypt(plain)
+		if err == nil {
+			rewritten.Mode = mode
+			rewritten.Hash, err = w.r.storeBlob(encrypted)
+		}
+	}
+	if err != nil {
+		return object.TreeEntry{}, err
+	}
+
+	rewritten.Name = entry.Name
+	w.blobs[key] = rewritten
+	if rewritten.Mode == filemode.Dir {
+		w.chunked[path] = &rewritten
+	}
+	return rewritten, nil
+}
@@ -0,0 +1,324 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	projectconfig "github.com/renatonmag/versionctrls-cli/pkg/config"
+)
+
+const (
+	// FileRefPrefix is where per-file snapshots live when they are kept out
+	// of refs/heads
+	FileRefPrefix = "refs/versionctrls/files/"
+
+	namespaceOption = "namespacedRefs"
+	namespaceSpec   = "refs/versionctrls/*:refs/versionctrls/*"
+)
+
+// refLayout remembers whether snapshots are under FileRefPrefix once it was
+// worked out from the git config and the references, which would otherwise
+// be done again for every file
+type refLayout struct {
+	mu         sync.Mutex
+	known      bool
+	namespaced bool
+}
+
+// UsesRefNamespace reports whether snapshots are stored under FileRefPrefix,
+// as integration.refs in the project configuration says. Without it, clones
+// set up before the option look at `git config versionctrls.namespacedRefs`
+// and then at the references they have. Installs that predate the
+// namespace keep using branches.
+func (r Repository) UsesRefNamespace() (bool, error) {
+	if r.repo == nil {
+		return false, errors.New("no repository opened")
+	}
+
+	switch r.integration.Refs {
+	case projectconfig.RefsNamespaced:
+		return true, nil
+	case projectconfig.RefsBranches:
+		return false, nil
+	}
+
+	if r.layout == nil {
+		return r.findRefLayout()
+	}
+	r.layout.mu.Lock()
+	defer r.layout.mu.Unlock()
+	if !r.layout.known {
+		namespaced, err := r.findRefLayout()
+		if err != nil {
+			return false, err
+		}
+		r.layout.known, r.layout.namespaced = true, namespaced
+	}
+	return r.layout.namespaced, nil
+}
+
+// findRefLayout looks for the namespace in the git config and then in the
+// references
+func (r Repository) findRefLayout() (bool, error) {
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return false, err
+	}
+	if cfg.Raw.Section(configSection).Option(namespaceOption) == "true" {
+		return true, nil
+	}
+
+	refs, err := r.repo.References()
+	if err != nil {
+		return false, err
+	}
+	defer refs.Close()
+
+	found := false
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), FileRefPrefix) {
+			found = true
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return found, err
+}
+
+// FileRefName returns the reference that holds the versions of file. A
+// reference still named the way it was before names were escaped is
+// renamed first, so its history carries on.
+func (r Repository) FileRefName(file string) (plumbing.ReferenceName, error) {
+	namespaced, err := r.UsesRefNamespace()
+	if err != nil {
+		return "", err
+	}
+
+	prefix := plumbing.ReferenceName("refs/heads/")
+	if namespaced {
+		prefix = FileRefPrefix
+	}
+	refName := prefix + plumbing.ReferenceName(FileBranchName(file))
+	legacy := prefix + plumbing.ReferenceName(legacyBranchName(file))
+	if legacy == refName {
+		return refName, nil
+	}
+
+	if _, err := r.repo.Reference(refName, false); err != plumbing.ErrReferenceNotFound {
+		return refName, err
+	}
+	return refName, r.adoptLegacyRef(legacy, refName, filepath.ToSlash(file))
+}
+
+// adoptLegacyRef moves legacy to refName when it holds the versions of path.
+// Other files whose path only differs in "/" and "-" had the same legacy
+// name, so the path the reference holds is checked first.
+func (r Repository) adoptLegacyRef(legacy, refName plumbing.ReferenceName, path string) error {
+	ref, err := r.repo.Reference(legacy, true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if held, err := r.RefPath(legacy); err != nil || held != path {
+		return nil
+	}
+
+	if err := r.repo.Storer.SetReference(plumbing.NewHashReference(refName, ref.Hash())); err != nil {
+		return fmt.Errorf("could not rename %s to %s: %w", legacy, refName, err)
+	}
+	if err := r.repo.Storer.RemoveReference(legacy); err != nil {
+		return fmt.Errorf("could not rename %s to %s: %w", legacy, refName, err)
+	}
+	return r.renameQueuedRef(legacy, refName)
+}
+
+// EnableRefNamespace stores new snapshots under FileRefPrefix and makes
+// every remote fetch and push that namespace
+func (r Repository) EnableRefNamespace() error {
+	if r.repo == nil {
+		return errors.New("no repository opened")
+	}
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg.Raw.Section(configSection).SetOption(namespaceOption, "true")
+
+	for name, remote := range cfg.Remotes {
+		spec := config.RefSpec("+" + namespaceSpec)
+		if !containsRefSpec(remote.Fetch, spec) {
+			remote.Fetch = append(remote.Fetch, spec)
+		}
+
+		// go-git has no field for push refspecs, so they go straight into
+		// the raw remote section that is written back with the config
+		section := cfg.Raw.Section("remote").Subsection(name)
+		if !containsString(section.Options.GetAll("push"), namespaceSpec) {
+			section.AddOption("push", namespaceSpec)
+		}
+	}
+
+	if err := r.repo.SetConfig(cfg); err != nil {
+		return err
+	}
+	if r.layout != nil {
+		r.layout.mu.Lock()
+		r.layout.known, r.layout.namespaced = true, true
+		r.layout.mu.Unlock()
+	}
+	return nil
+}
+
+// FileRefs returns every per-file snapshot reference, in refs/heads or in
+// the private namespace, mapped to the path it holds versions of
+func (r Repository) FileRefs() (map[plumbing.ReferenceName]string, error) {
+	if r.repo == nil {
+		return nil, errors.New("no repository opened")
+	}
+
+	namespaced, err := r.UsesRefNamespace()
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := r.repo.References()
+	if err != nil {
+		return nil, err
+	}
+	defer refs.Close()
+
+	head, _ := r.repo.Reference(plumbing.HEAD, false)
+
+	files := map[plumbing.ReferenceName]string{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		switch {
+		case namespaced && strings.HasPrefix(name.String(), FileRefPrefix):
+		case !namespaced && name.IsBranch():
+			// The checked out branch is the integration repository's own
+			if head != nil && head.Target() == name {
+				return nil
+			}
+		default:
+			return nil
+		}
+
+		path, err := r.RefPath(name)
+		if err != nil {
+			// Not a snapshot reference
+			return nil
+		}
+		files[name] = path
+		return nil
+	})
+
+	return files, err
+}
+
+// RefPath returns the path of the file whose versions are kept on refName.
+// It fails for references that do not hold snapshots.
+func (r Repository) RefPath(refName plumbing.ReferenceName) (string, error) {
+	_, tip, err := r.refTip(refName)
+	if err != nil {
+		return "", err
+	}
+	if tip == nil {
+		return "", plumbing.ErrReferenceNotFound
+	}
+
+	name := strings.TrimPrefix(refName.String(), FileRefPrefix)
+	if refName.IsBranch() {
+		name = refName.Short()
+	}
+
+	// Versions record their path, but only names that can be decoded can
+	// be trusted without looking
+	if path, ok := DecodeBranchName(name); ok {
+		if trailer, ok := commitTrailer(tip.Message, pathTrailer); ok && trailer == path {
+			return path, nil
+		}
+		if entry, err := r.findTreeEntry(tip.TreeHash, path); err == nil && entry != nil {
+			return path, nil
+		}
+	}
+
+	for commit := tip; commit != nil; {
+		if path, ok := commitTrailer(commit.Message, pathTrailer); ok {
+			return path, nil
+		}
+		if commit.NumParents() == 0 {
+			break
+		}
+		commit, err = commit.Parent(0)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Branches written before paths were recorded hold a README.md and the
+	// one file they keep versions of
+	files, err := tip.Files()
+	if err != nil {
+		return "", err
+	}
+	defer files.Close()
+
+	var paths []string
+	err = files.ForEach(func(f *object.File) error {
+		if f.Name != "README.md" {
+			paths = append(paths, f.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 1 {
+		return paths[0], nil
+	}
+
+	// Or only the README.md titled with their name when nothing was saved yet
+	if path, ok := DecodeBranchName(name); ok && len(paths) == 0 {
+		readme, err := tip.File("README.md")
+		if err == nil {
+			if content, err := readme.Contents(); err == nil && content == "# "+name {
+				return path, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%s does not hold versions of a file", refName)
+}
+
+func containsRefSpec(specs []config.RefSpec, spec config.RefSpec) bool {
+	for _, s := range specs {
+		if s == spec {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+)
+
+func TestRefLayoutFromProjectConfig(t *testing.T) {
+	testUser(t)
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Repository{repo: repo}
+
+	refName := func() string {
+		t.Helper()
+		name, err := r.FileRefName("main.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return name.String()
+	}
+
+	// A fresh clone has nothing in its git config, the project config says
+	// where snapshots are
+	if name := refName(); !strings.HasPrefix(name, "refs/heads/") {
+		t.Errorf("without configuration FileRefName = %s, want a branch", name)
+	}
+	r.SetIntegration(config.Integration{Refs: config.RefsNamespaced, Versioning: config.VersioningCounter})
+	if name := refName(); !strings.HasPrefix(name, FileRefPrefix) {
+		t.Errorf("with refs = namespaced FileRefName = %s, want it under %s", name, FileRefPrefix)
+	}
+	if scheme, err := r.VersionScheme(); err != nil || scheme != CounterScheme {
+		t.Errorf("VersionScheme = %s, %v, want %s", scheme, err, CounterScheme)
+	}
+
+	// Clones set up before the option find the namespace in their refs
+	save(t, r, dir, "main.go", "package main\n")
+	r.SetIntegration(config.Integration{})
+	if name := refName(); !strings.HasPrefix(name, FileRefPrefix) {
+		t.Errorf("with snapshots in the namespace FileRefName = %s, want it under %s", name, FileRefPrefix)
+	}
+	if scheme, err := r.VersionScheme(); err != nil || scheme != SemverScheme {
+		t.Errorf("VersionScheme = %s, %v, want %s", scheme, err, SemverScheme)
+	}
+
+	r.SetIntegration(config.Integration{Refs: config.RefsBranches})
+	if name := refName(); !strings.HasPrefix(name, "refs/heads/") {
+		t.Errorf("with refs = branches FileRefName = %s, want a branch", name)
+	}
+}
+
+func TestRefLayoutIsRemembered(t *testing.T) {
+	testUser(t)
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	if err := r.PlainOpen(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if namespaced, err := r.UsesRefNamespace(); err != nil || namespaced {
+		t.Fatalf("UsesRefNamespace = %v, %v in a new clone, want branches", namespaced, err)
+	}
+
+	// The references are not looked through again for every file
+	ref := plumbing.NewHashReference(FileRefPrefix+"main.go", plumbing.NewHash("0123456789012345678901234567890123456789"))
+	if err := r.repo.Storer.SetReference(ref); err != nil {
+		t.Fatal(err)
+	}
+	if namespaced, _ := r.UsesRefNamespace(); namespaced {
+		t.Error("UsesRefNamespace looked at the references again")
+	}
+
+	// But enabling the namespace is seen, also by copies
+	copied := *r
+	if err := r.EnableRefNamespace(); err != nil {
+		t.Fatal(err)
+	}
+	if namespaced, _ := copied.UsesRefNamespace(); !namespaced {
+		t.Error("UsesRefNamespace still reports branches after EnableRefNamespace")
+	}
+}
@@ -0,0 +1,383 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/renatonmag/versionctrls-cli/pkg/credentials"
+)
+
+const (
+	// DefaultRemote is the remote snapshots are pushed to and pulled from
+	DefaultRemote = "origin"
+
+	autoPushOption = "autoPush"
+	incomingPrefix = "refs/versionctrls-incoming/"
+)
+
+// sharedRefs are pushed and pulled along with the per-file references.
+// Pull fetches each of them to its incoming reference, outside
+// incomingPrefix where every reference is a file's.
+var sharedRefs = []struct {
+	name     plumbing.ReferenceName
+	incoming plumbing.ReferenceName
+}{
+	{ChangesetsRef, "refs/versionctrls-incoming-changesets"},
+	{CheckpointsRef, "refs/versionctrls-incoming-checkpoints"},
+}
+
+// ErrRemoteAhead is reported for references the remote has versions of that
+// were not pulled yet
+var ErrRemoteAhead = errors.New("the remote has versions that are not here yet, run ctrls pull first")
+
+// ErrDiverged is reported when local and remote versions of a file were both
+// saved on top of an older common version
+var ErrDiverged = errors.New("local and remote versions have diverged")
+
+// RefSyncResult is the outcome of pushing or pulling one snapshot reference
+type RefSyncResult struct {
+	Ref      plumbing.ReferenceName
+	Path     string
+	UpToDate bool
+	Err      error
+
+	// pushed is the local hash the reference was pushed at
+	pushed plumbing.Hash
+}
+
+// AutoPushEnabled reports whether snapshots should be pushed as soon as they
+// are taken, as set with `git config versionctrls.autoPush true`
+func (r Repository) AutoPushEnabled() (bool, error) {
+	if r.repo == nil {
+		return false, errors.New("no repository opened")
+	}
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return false, err
+	}
+
+	return cfg.Raw.Section(configSection).Option(autoPushOption) == "true", nil
+}
+
+// RemoteAuth returns how to authenticate against remoteName: HTTP basic auth
+// with the API key for its host, the SSH agent for SSH URLs, or nothing
+func (r Repository) RemoteAuth(remoteName string, store credentials.Store) (transport.AuthMethod, error) {
+	if r.repo == nil {
+		return nil, errors.New("no repository opened")
+	}
+
+	remote, err := r.repo.Remote(remoteName)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := transport.NewEndpoint(remote.Config().URLs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	switch endpoint.Protocol {
+	case "http", "https":
+		cred, err := store.Get(endpoint.Host)
+		if errors.Is(err, credentials.ErrNotFound) {
+			// Public or locally served remotes need no credentials
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &http.BasicAuth{Username: cred.UsernameOrDefault(), Password: cred.Secret}, nil
+
+	case "ssh":
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
+		return ssh.NewSSHAgentAuth(user)
+
+	default:
+		return nil, nil
+	}
+}
+
+// PushSnapshots pushes every per-file snapshot reference to remoteName.
+// With force the remote references are replaced even when that drops
+// versions, which is what rewriting history with ReencryptHistory needs.
+func (r Repository) PushSnapshots(remoteName string, auth transport.AuthMethod, force bool) ([]RefSyncResult, error) {
+	files, err := r.FileRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]plumbing.ReferenceName, 0, len(files)+len(sharedRefs))
+	for ref := range files {
+		refs = append(refs, ref)
+	}
+	for _, shared := range sharedRefs {
+		if _, err := r.repo.Reference(shared.name, true); err == nil {
+			refs = append(refs, shared.name)
+		}
+	}
+
+	return r.pushRefs(remoteName, auth, refs, force)
+}
+
+// PushRefs pushes refs to remoteName. References that cannot be fast-forwarded
+// on the remote are reported as failed without stopping the others, and stay
+// in the outbox until a later attempt succeeds.
+func (r Repository) PushRefs(remoteName string, auth transport.AuthMethod, refs []plumbing.ReferenceName) ([]RefSyncResult, error) {
+	return r.pushRefs(remoteName, auth, refs, false)
+}
+
+// pushRefs is PushRefs, replacing the remote references without checking
+// for a fast-forward when force is set
+func (r Repository) pushRefs(remoteName string, auth transport.AuthMethod, refs []plumbing.ReferenceName, force bool) ([]RefSyncResult, error) {
+	if r.repo == nil {
+		return nil, errors.New("no repository opened")
+	}
+
+	remote, err := r.repo.Remote(remoteName)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteRefs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		// Typically the remote is unreachable, so every reference stays
+		// queued and is tried again later
+		err = fmt.Errorf("could not list %s: %w", remoteName, err)
+		failed := make([]RefSyncResult, len(refs))
+		for i, ref := range refs {
+			failed[i] = RefSyncResult{Ref: ref, Err: err}
+		}
+		if settleErr := r.settleOutbox(failed); settleErr != nil {
+			return nil, fmt.Errorf("could not update the outbox: %w", settleErr)
+		}
+		return nil, err
+	}
+	remoteHashes := map[plumbing.ReferenceName]plumbing.Hash{}
+	for _, ref := range remoteRefs {
+		remoteHashes[ref.Name()] = ref.Hash()
+	}
+
+	results := make([]RefSyncResult, len(refs))
+	var batch []int
+	var single [][]int
+	for i, name := range refs {
+		results[i] = RefSyncResult{Ref: name}
+		results[i].Path, _ = r.RefPath(name)
+
+		local, err := r.repo.Reference(name, true)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].pushed = local.Hash()
+
+		remoteHash, exists := remoteHashes[name]
+		switch {
+		case exists && remoteHash == local.Hash():
+			results[i].UpToDate = true
+			continue
+		case exists && !force:
+			if err := r.checkFastForward(remoteHash, local.Hash()); err != nil {
+				results[i].Err = err
+				continue
+			}
+		}
+
+		// go-git formats every update command but the first with the ref
+		// name as a printf format, so names with escapes go one at a time
+		if strings.Contains(name.String(), "%") {
+			single = append(single, []int{i})
+		} else {
+			batch = append(batch, i)
+		}
+	}
+
+	for _, group := range append(single, batch) {
+		if len(group) == 0 {
+			continue
+		}
+
+		specs := make([]config.RefSpec, len(group))
+		for j, i := range group {
+			specs[j] = config.RefSpec(refs[i] + ":" + refs[i])
+			if force {
+				specs[j] = "+" + specs[j]
+			}
+		}
+
+		err := r.repo.Push(&git.PushOptions{
+			RemoteName: remoteName,
+			RefSpecs:   specs,
+			Auth:       auth,
+		})
+		if errors.Is(err, git.NoErrAlreadyUpToDate) {
+			err = nil
+		}
+		for _, i := range group {
+			results[i].Err = err
+		}
+	}
+
+	if err := r.settleOutbox(results); err != nil {
+		return results, fmt.Errorf("could not update the outbox: %w", err)
+	}
+	return results, nil
+}
+
+// checkFastForward fails unless moving from the remote hash to the local one
+// only adds versions
+func (r Repository) checkFastForward(remote, local plumbing.Hash) error {
+	remoteCommit, err := r.repo.CommitObject(remote)
+	if err == plumbing.ErrObjectNotFound {
+		return ErrRemoteAhead
+	}
+	if err != nil {
+		return err
+	}
+
+	localCommit, err := r.repo.CommitObject(local)
+	if err != nil {
+		return err
+	}
+
+	ancestor, err := remoteCommit.IsAncestor(localCommit)
+	if err != nil {
+		return err
+	}
+	if ancestor {
+		return nil
+	}
+
+	behind, err := localCommit.IsAncestor(remoteCommit)
+	if err != nil {
+		return err
+	}
+	if behind {
+		return ErrRemoteAhead
+	}
+	return ErrDiverged
+}
+
+// PullSnapshots fetches the snapshot references of remoteName and moves the
+// local ones forward. References that diverged are reported and left alone.
+func (r Repository) PullSnapshots(remoteName string, auth transport.AuthMethod) ([]RefSyncResult, error) {
+	if r.repo == nil {
+		return nil, errors.New("no repository opened")
+	}
+
+	namespaced, err := r.UsesRefNamespace()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := FileRefPrefix
+	if !namespaced {
+		prefix = "refs/heads/"
+	}
+
+	// Everything lands in a scratch namespace first so that one transfer is
+	// enough and each reference can then be moved on its own
+	specs := []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s*:%s*", prefix, incomingPrefix))}
+	for _, shared := range sharedRefs {
+		// A pattern, so that remotes without the reference are not an error
+		specs = append(specs, config.RefSpec(fmt.Sprintf("+%s*:%s*", shared.name, shared.incoming)))
+	}
+	err = r.repo.Fetch(&git.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   specs,
+		Auth:       auth,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil, fmt.Errorf("could not fetch from %s: %w", remoteName, err)
+	}
+
+	refs, err := r.repo.References()
+	if err != nil {
+		return nil, err
+	}
+	var incoming []*plumbing.Reference
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), incomingPrefix) {
+			incoming = append(incoming, ref)
+		}
+		return nil
+	})
+	refs.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	head, _ := r.repo.Reference(plumbing.HEAD, false)
+
+	var results []RefSyncResult
+	for _, ref := range incoming {
+		name := plumbing.ReferenceName(prefix + strings.TrimPrefix(ref.Name().String(), incomingPrefix))
+		if head != nil && head.Target() == name {
+			// The checked out branch is not a snapshot
+			r.repo.Storer.RemoveReference(ref.Name())
+			continue
+		}
+		if _, err := r.RefPath(ref.Name()); err != nil {
+			// Nor are other branches of the integration repository
+			r.repo.Storer.RemoveReference(ref.Name())
+			continue
+		}
+
+		result := RefSyncResult{Ref: name}
+		result.UpToDate, result.Err = r.fastForwardRef(name, ref.Hash())
+		result.Path, _ = r.RefPath(name)
+		results = append(results, result)
+
+		r.repo.Storer.RemoveReference(ref.Name())
+	}
+
+	for _, shared := range sharedRefs {
+		ref, err := r.repo.Reference(shared.incoming, true)
+		if err != nil {
+			continue
+		}
+		result := RefSyncResult{Ref: shared.name}
+		result.UpToDate, result.Err = r.fastForwardRef(shared.name, ref.Hash())
+		results = append(results, result)
+		r.repo.Storer.RemoveReference(ref.Name())
+	}
+
+	return results, nil
+}
+
+// fastForwardRef moves the local reference name to hash if that only adds
+// versions, reporting whether it already had them
+func (r Repository) fastForwardRef(name plumbing.ReferenceName, hash plumbing.Hash) (bool, error) {
+	local, err := r.repo.Reference(name, true)
+	if err == plumbing.ErrReferenceNotFound {
+		return false, r.advanceRef(name, hash, nil)
+	}
+	if err != nil {
+		return false, err
+	}
+	if local.Hash() == hash {
+		return true, nil
+	}
+
+	// Pulling the same versions back after a push is not an update
+	err = r.checkFastForward(hash, local.Hash())
+	if err == nil {
+		return true, nil
+	}
+	if err != ErrRemoteAhead {
+		return false, err
+	}
+
+	return false, r.advanceRef(name, hash, local)
+}
@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// testUser gives commits an author without touching the real global config
+func testUser(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = Tester\n\temail = tester@example.com\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newRemote creates a bare integration repository to push to and pull from
+func newRemote(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "remote.git")
+	if err := InitBareIntegration(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newClone creates an integration worktree with remote as its origin
+func newClone(t *testing.T, remote string) (*Repository, string) {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: DefaultRemote, URLs: []string{remote}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Repository{repo: repo}
+	if err := r.EnableRefNamespace(); err != nil {
+		t.Fatal(err)
+	}
+	return r, dir
+}
+
+// save writes content to file in the clone at dir and saves a version of it
+func save(t *testing.T, r *Repository, dir, file, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commit, err := r.SaveFile(file)
+	if err != nil {
+		t.Fatalf("SaveFile(%s): %v", file, err)
+	}
+	if commit.IsZero() {
+		t.Fatalf("SaveFile(%s) saved nothing", file)
+	}
+}
+
+// resultsByPath maps sync results to the path of their reference
+func resultsByPath(t *testing.T, r *Repository, results []RefSyncResult) map[string]RefSyncResult {
+	t.Helper()
+	byPath := map[string]RefSyncResult{}
+	for _, result := range results {
+		path := result.Path
+		if path == "" {
+			path, _ = r.RefPath(result.Ref)
+		}
+		byPath[path] = result
+	}
+	return byPath
+}
+
+func readVersion(t *testing.T, r *Repository, file string) string {
+	t.Helper()
+	versions, err := r.FileVersions(file)
+	if err != nil {
+		t.Fatalf("FileVersions(%s): %v", file, err)
+	}
+	content, err := r.ReadVersion(versions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestPushPullRoundTrip(t *testing.T) {
+	testUser(t)
+	remote := newRemote(t)
+
+	files := map[string]string{
+		"main.go":        "package main\n",
+		"dir/a-b.txt":    "dashes\n",
+		"100%.txt":       "percent\n",
+		"docs/50%/x.md":  "nested percent\n",
+		"ünïcödé.txt":    "unicode\n",
+		".hidden/.f.txt": "dots\n",
+	}
+
+	a, dirA := newClone(t, remote)
+	for file, content := range files {
+		save(t, a, dirA, file, content)
+	}
+
+	results, err := a.PushSnapshots(DefaultRemote, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(files) {
+		t.Errorf("pushed %d references, want %d", len(results), len(files))
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("push %s: %v", result.Ref, result.Err)
+		}
+	}
+
+	again, err := a.PushSnapshots(DefaultRemote, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range again {
+		if result.Err != nil || !result.UpToDate {
+			t.Errorf("second push of %s: up to date %v, %v", result.Ref, result.UpToDate, result.Err)
+		}
+	}
+
+	b, _ := newClone(t, remote)
+	pulled, err := b.PullSnapshots(DefaultRemote, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range pulled {
+		if result.Err != nil {
+			t.Errorf("pull %s: %v", result.Ref, result.Err)
+		}
+	}
+
+	refs, err := b.FileRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != len(files) {
+		t.Errorf("pulled %d files, want %d: %v", len(refs), len(files), refs)
+	}
+	for file, content := range files {
+		if got := readVersion(t, b, file); got != content {
+			t.Errorf("pulled %s = %q, want %q", file, got, content)
+		}
+	}
+}
+
+func TestPushRejectsNonFastForward(t *testing.T) {
+	testUser(t)
+	remote := newRemote(t)
+
+	a, dirA := newClone(t, remote)
+	save(t, a, dirA, "shared%.txt", "one\n")
+	if _, err := a.PushSnapshots(DefaultRemote, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	b, dirB := newClone(t, remote)
+	if _, err := b.PullSnapshots(DefaultRemote, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both save on top of the same version, a pushes first
+	save(t, a, dirA, "shared%.txt", "two from a\n")
+	if _, err := a.PushSnapshots(DefaultRemote, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	save(t, b, dirB, "shared%.txt", "two from b\n")
+	save(t, b, dirB, "only-b.txt", "b\n")
+
+	results, err := b.PushSnapshots(DefaultRemote, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := resultsByPath(t, b, results)
+	if err := byPath["shared%.txt"].Err; !errors.Is(err, ErrRemoteAhead) && !errors.Is(err, ErrDiverged) {
+		t.Errorf("push of a diverged file: %v, want it rejected", err)
+	}
+	if err := byPath["only-b.txt"].Err; err != nil {
+		t.Errorf("push of the other file failed along: %v", err)
+	}
+
+	// The rejected version stays queued, the pushed one does not
+	outbox, err := b.Outbox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox) != 1 || outbox[0].Path != "shared%.txt" {
+		t.Errorf("outbox = %+v, want only shared%%.txt", outbox)
+	}
+
+	pulled, err := b.PullSnapshots(DefaultRemote, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resultsByPath(t, b, pulled)["shared%.txt"].Err; !errors.Is(err, ErrDiverged) {
+		t.Errorf("pull of a diverged file: %v, want ErrDiverged", err)
+	}
+	if got := readVersion(t, b, "shared%.txt"); got != "two from b\n" {
+		t.Errorf("diverged pull changed the local version to %q", got)
+	}
+
+	// Forcing replaces the remote version
+	results, err = b.PushSnapshots(DefaultRemote, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resultsByPath(t, b, results)["shared%.txt"].Err; err != nil {
+		t.Errorf("forced push: %v", err)
+	}
+
+	c, _ := newClone(t, remote)
+	if _, err := c.PullSnapshots(DefaultRemote, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readVersion(t, c, "shared%.txt"); got != "two from b\n" {
+		t.Errorf("after a forced push the remote has %q", got)
+	}
+
+	// Unknown references fail on their own
+	results, err = b.PushRefs(DefaultRemote, nil, []plumbing.ReferenceName{"refs/versionctrls/files/missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err == nil {
+		t.Errorf("pushing a missing reference: %+v", results)
+	}
+}
+
+func TestOutboxKeepsVersionsSavedDuringPush(t *testing.T) {
+	testUser(t)
+	a, dir := newClone(t, newRemote(t))
+	save(t, a, dir, "main.go", "package main\n")
+
+	refName, err := a.FileRefName("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := a.repo.Reference(refName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The push read the first version, the second is saved before it settles
+	save(t, a, dir, "main.go", "package main\n\nfunc main() {}\n")
+	if err := a.settleOutbox([]RefSyncResult{{Ref: refName, pushed: ref.Hash()}}); err != nil {
+		t.Fatal(err)
+	}
+	outbox, err := a.Outbox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox) != 1 || outbox[0].Attempts != 0 {
+		t.Fatalf("outbox = %+v, want main.go still queued", outbox)
+	}
+
+	results, err := a.RetryOutbox(DefaultRemote, nil, false)
+	if err != nil || len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("RetryOutbox = %+v, %v", results, err)
+	}
+	if outbox, _ := a.Outbox(); len(outbox) != 0 {
+		t.Errorf("outbox = %+v after pushing the latest version, want it empty", outbox)
+	}
+}
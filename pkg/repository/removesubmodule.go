@@ -83,6 +83,9 @@ func (r *Repository) RemoveSubmodule() error {
 	if r.repo == nil {
 		return errors.New("no repository opened")
 	}
+	if r.submodulePath == "" {
+		return errors.New("no integration submodule path configured")
+	}
 	repoRoot, _ := r.GetRepoRoot()
 
 	// Remove the submodule entry from the .git/config.
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// RenameFile records that from was renamed to to, whose current content is
+// saved as its next version. The branch of from gets a tombstone naming to,
+// and the first version on the branch of to names from and has the tombstone
+// as a second parent, so the history of the file can be followed across the
+// rename. Renames that were already recorded only save to.
+func (r Repository) RenameFile(from, to string) (plumbing.Hash, error) {
+	if r.repo == nil {
+		return plumbing.ZeroHash, errors.New("no repository opened")
+	}
+
+	from, to = filepath.ToSlash(from), filepath.ToSlash(to)
+
+	versions, err := r.FileVersions(from)
+	if errors.Is(err, ErrNoVersions) {
+		// Nothing to carry over
+		return r.SaveFile(to)
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	refName, err := r.FileRefName(from)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	_, tip, err := r.refTip(refName)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if renamedTo, ok := commitTrailer(tip.Message, renamedToTrailer); ok && renamedTo == to {
+		return r.SaveFile(to)
+	}
+
+	// A file already recorded as deleted still gets a tombstone naming where
+	// it went
+	message := buildMessage(from+" renamed to "+to, Trailer{pathTrailer, from}, Trailer{renamedToTrailer, to})
+	link, err := r.tombstone(from, message, true)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := r.CreateEmptyFileRef(to); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not create branch for %s: %w", to, err)
+	}
+	toRef, err := r.FileRefName(to)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// Numbering carries on from the old path
+	message, err = r.versionMessage(to, versions, Trailer{renamedFromTrailer, from})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not number version: %w", err)
+	}
+
+	commit, err := r.snapshotFile(to, toRef, message, link)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := r.QueueRef(toRef, to); err != nil {
+		return commit, fmt.Errorf("could not queue %s for push: %w", to, err)
+	}
+	return commit, nil
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestRenameAndDeleteFile(t *testing.T) {
+	testUser(t)
+	r, dir := newClone(t, newRemote(t))
+	tip := func(file string) *object.Commit {
+		t.Helper()
+		refName, err := r.FileRefName(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, commit, err := r.refTip(refName)
+		if err != nil || commit == nil {
+			t.Fatalf("no branch for %s: %v", file, err)
+		}
+		return commit
+	}
+
+	save(t, r, dir, "a.txt", "a1\n")
+	save(t, r, dir, "a.txt", "a2\n")
+	save(t, r, dir, "b.txt", "b\n")
+
+	if err := os.Rename(filepath.Join(dir, "a.txt"), filepath.Join(dir, "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+	commit, err := r.RenameFile("a.txt", "c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tombstone := tip("a.txt")
+	if to, ok := commitTrailer(tombstone.Message, renamedToTrailer); !ok || to != "c.txt" {
+		t.Errorf("tombstone of a.txt has %s %q, want c.txt", renamedToTrailer, to)
+	}
+	if entry, err := r.findTreeEntry(tombstone.TreeHash, "a.txt"); err != nil || entry != nil {
+		t.Errorf("tombstone of a.txt still holds it: %v", err)
+	}
+
+	first := tip("c.txt")
+	if first.Hash != commit {
+		t.Errorf("RenameFile returned %s, the tip of c.txt is %s", commit, first.Hash)
+	}
+	if from, ok := commitTrailer(first.Message, renamedFromTrailer); !ok || from != "a.txt" {
+		t.Errorf("first version of c.txt has %s %q, want a.txt", renamedFromTrailer, from)
+	}
+	if len(first.ParentHashes) != 2 || first.ParentHashes[1] != tombstone.Hash {
+		t.Errorf("first version of c.txt has parents %v, want the tombstone %s second", first.ParentHashes, tombstone.Hash)
+	}
+
+	// History follows c.txt back to the versions saved as a.txt
+	versions, err := r.FileVersions("c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, v := range versions {
+		paths = append(paths, v.Path)
+	}
+	if len(versions) != 3 || paths[0] != "c.txt" || paths[1] != "a.txt" || paths[2] != "a.txt" {
+		t.Fatalf("versions of c.txt are at %v, want [c.txt a.txt a.txt]", paths)
+	}
+	if versions[0].RenamedFrom != "a.txt" {
+		t.Errorf("newest version of c.txt renamed from %q, want a.txt", versions[0].RenamedFrom)
+	}
+	if content, err := r.ReadVersion(versions[2]); err != nil || string(content) != "a1\n" {
+		t.Errorf("oldest version of c.txt reads %q, %v, want a1", content, err)
+	}
+
+	// Recording the same rename again adds nothing
+	if _, err := r.RenameFile("a.txt", "c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if again := tip("c.txt"); again.Hash != first.Hash {
+		t.Errorf("renaming again moved c.txt to %s", again.Hash)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	commit, err = r.DeleteFile("b.txt")
+	if err != nil || commit.IsZero() {
+		t.Fatalf("DeleteFile(b.txt) = %s, %v", commit, err)
+	}
+	if _, ok := commitTrailer(tip("b.txt").Message, renamedToTrailer); ok {
+		t.Errorf("deleted b.txt has a %s trailer", renamedToTrailer)
+	}
+	if commit, err := r.DeleteFile("b.txt"); err != nil || !commit.IsZero() {
+		t.Errorf("deleting b.txt again = %s, %v, want nothing recorded", commit, err)
+	}
+
+	files, err := r.SavedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := map[string]SavedFile{}
+	for _, f := range files {
+		saved[f.Path] = f
+	}
+	if f := saved["a.txt"]; f.Deleted.IsZero() || f.RenamedTo != "c.txt" {
+		t.Errorf("a.txt is listed as %+v, want it renamed to c.txt", f)
+	}
+	if f := saved["b.txt"]; f.Deleted.IsZero() || f.RenamedTo != "" {
+		t.Errorf("b.txt is listed as %+v, want it deleted", f)
+	}
+	if f := saved["c.txt"]; !f.Deleted.IsZero() {
+		t.Errorf("c.txt is listed as deleted")
+	}
+}
@@ -1,16 +1,46 @@
 package repository
 
-import "github.com/go-git/go-git/v5"
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/encryption"
+	"github.com/renatonmag/versionctrls-cli/pkg/ignore"
+	"github.com/renatonmag/versionctrls-cli/pkg/secrets"
+)
 
 type Repository struct {
 	repo          *git.Repository
 	submodulePath string
+	integration   config.Integration
+	largeFiles    config.LargeFiles
+	ignore        *ignore.Matcher
+	secrets       *secrets.Scanner
+	keys          *encryption.Keyring
+	changeset     *pendingChangeset
+	layout        *refLayout
 }
 
 // New creates a new Repository
 func New() *Repository {
 	return &Repository{
-		repo:          nil,
-		submodulePath: "versionctrls-integration",
+		repo: nil,
 	}
 }
+
+// SetSubmodulePath sets where the integration submodule lives, relative to
+// the repository root, usually from the project configuration
+func (r *Repository) SetSubmodulePath(path string) {
+	r.submodulePath = path
+}
+
+// SubmodulePath returns the path of the integration submodule relative to the repository root
+func (r *Repository) SubmodulePath() string {
+	return r.submodulePath
+}
+
+// SetIntegration sets where snapshots are kept and how versions are
+// numbered, usually from the project configuration. Empty fields fall back
+// to the git config of the integration repository.
+func (r *Repository) SetIntegration(integration config.Integration) {
+	r.integration = integration
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// ReadVersion returns the content of a saved version
+func (r Repository) ReadVersion(v Version) ([]byte, error) {
+	if r.repo == nil {
+		return nil, errors.New("no repository opened")
+	}
+
+	return r.readEntry(v.blob, v.mode)
+}
+
+// WriteVersion writes the content of a saved version to dst with its file
+// mode, creating any missing parent directories. Symlinks are written back
+// as symlinks.
+func (r Repository) WriteVersion(v Version, dst string) error {
+	content, err := r.ReadVersion(v)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(dst), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	if v.mode == filemode.Symlink {
+		return utils.WriteSymlinkAtomic(dst, string(content))
+	}
+
+	perm := os.FileMode(0644)
+	if v.mode == filemode.Executable {
+		perm = 0755
+	}
+	return utils.WriteFileAtomic(dst, content, perm)
+}
+
+// IsSaved reports whether content is what the latest version of file holds.
+// Content is compared the way it would be versioned, with secrets redacted.
+func (r Repository) IsSaved(file string, content []byte) (bool, error) {
+	if redacted, _, err := r.checkSecrets(filepath.ToSlash(file), content); err == nil {
+		content = redacted
+	}
+
+	versions, err := r.FileVersions(file)
+	if errors.Is(err, ErrNoVersions) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	latest := versions[0]
+	if latest.mode != filemode.Dir && latest.blob == plumbing.ComputeHash(plumbing.BlobObject, content) {
+		return true, nil
+	}
+	if latest.Size != int64(len(content)) {
+		return false, nil
+	}
+
+	// Chunked and LFS versions only compare by content
+	saved, err := r.ReadVersion(latest)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(saved, content), nil
+}
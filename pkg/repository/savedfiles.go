@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// SavedFile is a file with saved versions as the tip of its branch sees it
+type SavedFile struct {
+	Path string `json:"path"`
+	// Latest is the newest saved version
+	Latest Version `json:"latest"`
+	// Deleted is when the deletion of the file was recorded, zero while it
+	// is not
+	Deleted time.Time `json:"deleted,omitempty"`
+	// RenamedTo is where the file went when it was deleted by a rename
+	RenamedTo string `json:"renamedTo,omitempty"`
+}
+
+// SavedFiles returns every file with saved versions, sorted by path
+func (r Repository) SavedFiles() ([]SavedFile, error) {
+	if r.repo == nil {
+		return nil, errors.New("no repository opened")
+	}
+
+	refs, err := r.FileRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []SavedFile
+	for refName, path := range refs {
+		versions, err := r.FileVersions(path)
+		if errors.Is(err, ErrNoVersions) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		file := SavedFile{Path: path, Latest: versions[0]}
+
+		_, tip, err := r.refTip(refName)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := r.findTreeEntry(tip.TreeHash, path)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			// The tip is a tombstone
+			file.Deleted = tip.Author.When
+			file.RenamedTo, _ = commitTrailer(tip.Message, renamedToTrailer)
+		}
+
+		files = append(files, file)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// SaveFile records the current content of file on its own branch, creating
+// the branch first if needed and queueing it for push. It returns
+// plumbing.ZeroHash when nothing changed.
+func (r Repository) SaveFile(file string) (plumbing.Hash, error) {
+	if r.repo == nil {
+		return plumbing.ZeroHash, errors.New("no repository opened")
+	}
+
+	err := r.CreateEmptyFileRef(file)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not create branch for %s: %w", file, err)
+	}
+
+	refName, err := r.FileRefName(file)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	message, err := r.nextVersionMessage(file)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not number version: %w", err)
+	}
+
+	commit, err := r.SnapshotFile(file, refName, message)
+	if err != nil || commit.IsZero() {
+		return commit, err
+	}
+
+	// The version only exists here until it is pushed
+	if err := r.QueueRef(refName, filepath.ToSlash(file)); err != nil {
+		return commit, fmt.Errorf("could not queue %s for push: %w", file, err)
+	}
+	return commit, nil
+}
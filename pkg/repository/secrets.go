@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/secrets"
+)
+
+// SetSecrets sets the scanner that checks files for secrets before they
+// are copied or versioned
+func (r *Repository) SetSecrets(scanner *secrets.Scanner) {
+	r.secrets = scanner
+}
+
+// SecretScanner returns the scanner set with SetSecrets
+func (r Repository) SecretScanner() *secrets.Scanner {
+	return r.secrets
+}
+
+// checkSecrets returns the content of path to version along with what the
+// scanner found in it. Files with findings are redacted or, when the
+// scanner blocks them, rejected with a *secrets.Error.
+func (r Repository) checkSecrets(path string, content []byte) ([]byte, []secrets.Finding, error) {
+	action := r.secrets.Action()
+	if action == config.SecretsOff {
+		return content, nil, nil
+	}
+
+	findings := r.secrets.Scan(path, content)
+	switch {
+	case len(findings) == 0:
+		return content, nil, nil
+	case action == config.SecretsRedact:
+		return secrets.Redact(content, findings), findings, nil
+	default:
+		return nil, findings, &secrets.Error{Path: path, Findings: findings}
+	}
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SelectVersion picks from versions, newest first, the one identified by
+// selector: a version number such as 3, v0.1.2 or file.go-v0.1.2, or a
+// commit hash or hash prefix. The newest match wins when several versions
+// share a label.
+func SelectVersion(versions []Version, selector string) (Version, error) {
+	selector = strings.TrimSpace(selector)
+	if selector == "" {
+		return Version{}, fmt.Errorf("empty version")
+	}
+
+	for _, v := range versions {
+		if v.Label == "" {
+			continue
+		}
+		if selector == v.Label || selector == "v"+v.Label || selector == v.Path+"-v"+v.Label {
+			return v, nil
+		}
+	}
+
+	for _, v := range versions {
+		if len(selector) >= 4 && strings.HasPrefix(v.Hash, strings.ToLower(selector)) {
+			return v, nil
+		}
+	}
+
+	return Version{}, fmt.Errorf("no version %q", selector)
+}
+
+// VersionAt returns the newest of versions, newest first, saved at or before t
+func VersionAt(versions []Version, t time.Time) (Version, error) {
+	for _, v := range versions {
+		if !v.When.After(t) {
+			return v, nil
+		}
+	}
+
+	return Version{}, fmt.Errorf("no version saved at or before %s", t.Format("2006-01-02 15:04:05"))
+}
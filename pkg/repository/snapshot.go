@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// SnapshotFile records the worktree content of path as a new commit on refName.
+// It only writes objects and moves refName, so HEAD, the index and the
+// worktree are left exactly as they were. When the content is the same as in
+// the tip of refName no commit is made and plumbing.ZeroHash is returned.
+func (r Repository) SnapshotFile(path string, refName plumbing.ReferenceName, message string) (plumbing.Hash, error) {
+	return r.snapshotFile(path, refName, message, plumbing.ZeroHash)
+}
+
+// snapshotFile is SnapshotFile with link, when it is not plumbing.ZeroHash,
+// as a second parent of the commit. Linked commits are made even when the
+// content did not change, since the link is what they record.
+func (r Repository) snapshotFile(path string, refName plumbing.ReferenceName, message string, link plumbing.Hash) (plumbing.Hash, error) {
+	if r.repo == nil {
+		return plumbing.ZeroHash, errors.New("no repository opened")
+	}
+
+	path = filepath.ToSlash(path)
+
+	content, mode, err := r.readWorktreeFile(path)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if mode != filemode.Symlink {
+		content, _, err = r.checkSecrets(path, content)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	ref, tip, err := r.refTip(refName)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	var current *object.TreeEntry
+	if tip != nil {
+		current, err = r.findTreeEntry(tip.TreeHash, path)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	// Encrypting the same content twice gives different blobs, so encrypted
+	// versions are compared by their plain content before storing anything
+	if current != nil && link.IsZero() && r.keys != nil && mode != filemode.Symlink {
+		same, err := r.sameContent(current, content, mode)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if same {
+			return plumbing.ZeroHash, nil
+		}
+	}
+
+	stored, err := r.storeContent(path, content, mode, current)
+	if errors.Is(err, ErrFileTooLarge) {
+		return plumbing.ZeroHash, err
+	}
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not store content of %s: %w", path, err)
+	}
+
+	baseTree := plumbing.ZeroHash
+	var parents []plumbing.Hash
+	if tip != nil {
+		baseTree = tip.TreeHash
+		parents = []plumbing.Hash{tip.Hash}
+
+		if current != nil && current.Hash == stored.Hash && current.Mode == stored.Mode && link.IsZero() {
+			return plumbing.ZeroHash, nil
+		}
+	}
+	if !link.IsZero() {
+		parents = append(parents, link)
+	}
+
+	tree, err := r.updateTree(baseTree, path, &stored)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not build tree for %s: %w", path, err)
+	}
+
+	if r.changeset != nil {
+		message = addTrailer(message, Trailer{changesetTrailer, r.changeset.id})
+	}
+	commit, err := r.storeCommit(tree, parents, message)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("could not store commit for %s: %w", path, err)
+	}
+
+	if err := r.advanceRef(refName, commit, ref); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	r.changeset.record(path, false)
+
+	return commit, nil
+}
+
+// readWorktreeFile reads path relative to the worktree root along with the
+// git file mode it should be recorded with. Symlinks are read as their
+// target, the way git stores them.
+func (r Repository) readWorktreeFile(path string) ([]byte, filemode.FileMode, error) {
+	root, err := r.worktreeRoot()
+	if err != nil {
+		return nil, filemode.Empty, err
+	}
+
+	fullPath := filepath.Join(root, filepath.FromSlash(path))
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return nil, filemode.Empty, err
+	}
+	if info.IsDir() {
+		return nil, filemode.Empty, fmt.Errorf("%s is a directory", path)
+	}
+
+	mode, err := filemode.NewFromOSFileMode(info.Mode())
+	if err != nil {
+		return nil, filemode.Empty, err
+	}
+
+	content, err := utils.ReadFileOrLink(fullPath)
+	if err != nil {
+		return nil, filemode.Empty, err
+	}
+
+	return content, mode, nil
+}
+
+// worktreeRoot returns the absolute path of the repository worktree
+func (r Repository) worktreeRoot() (string, error) {
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Abs(worktree.Filesystem.Root())
+}
+
+// sameContent reports whether entry records content with mode, comparing
+// plain content. Chunked entries keep no mode, so they match any file.
+func (r Repository) sameContent(entry *object.TreeEntry, content []byte, mode filemode.FileMode) (bool, error) {
+	if entry.Mode != mode && entry.Mode != filemode.Dir {
+		return false, nil
+	}
+
+	saved, err := r.readEntry(entry.Hash, entry.Mode)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(saved, content), nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Trailer is a "Key: value" line at the end of a commit message
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// buildMessage joins a subject line and trailers into a commit message
+func buildMessage(subject string, trailers ...Trailer) string {
+	if len(trailers) == 0 {
+		return subject + "\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(subject)
+	b.WriteString("\n\n")
+	for _, t := range trailers {
+		fmt.Fprintf(&b, "%s: %s\n", t.Key, t.Value)
+	}
+	return b.String()
+}
+
+// commitTrailer returns the value of the last trailer named key in message
+func commitTrailer(message, key string) (string, bool) {
+	paragraphs := strings.Split(strings.TrimSpace(message), "\n\n")
+	if len(paragraphs) < 2 {
+		return "", false
+	}
+
+	value, found := "", false
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(k), key) {
+			value, found = strings.TrimSpace(v), true
+		}
+	}
+	return value, found
+}
+
+// commitTrailers returns the values of every trailer named key in message,
+// in order
+func commitTrailers(message, key string) []string {
+	paragraphs := strings.Split(strings.TrimSpace(message), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+
+	var values []string
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(k), key) {
+			values = append(values, strings.TrimSpace(v))
+		}
+	}
+	return values
+}
+
+// addTrailer appends t to the trailers of message, starting them if it has
+// none
+func addTrailer(message string, t Trailer) string {
+	message = strings.TrimRight(message, "\n")
+	if strings.Contains(message, "\n\n") {
+		return fmt.Sprintf("%s\n%s: %s\n", message, t.Key, t.Value)
+	}
+	return buildMessage(message, t)
+}
+
+// commitSubject returns the first line of message
+func commitSubject(message string) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return subject
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// VersionScheme is how consecutive versions of a file are numbered
+type VersionScheme string
+
+const (
+	// SemverScheme bumps the patch number: 0.1.0, 0.1.1, 0.1.2...
+	SemverScheme VersionScheme = "semver"
+	// CounterScheme counts versions: 1, 2, 3...
+	CounterScheme VersionScheme = "counter"
+)
+
+const (
+	configSection    = "versionctrls"
+	versioningOption = "versioning"
+	versionTrailer   = "Version"
+	pathTrailer      = "Path"
+	firstSemver      = "0.1.0"
+
+	// renamedFromTrailer marks the first version of a file under its new
+	// path and renamedToTrailer the commit that retires the old one
+	renamedFromTrailer = "Renamed-From"
+	renamedToTrailer   = "Renamed-To"
+)
+
+// VersionScheme returns the numbering scheme set with integration.versioning
+// in the project configuration, or else with
+// `git config versionctrls.versioning`, defaulting to semver
+func (r Repository) VersionScheme() (VersionScheme, error) {
+	scheme := VersionScheme(r.integration.Versioning)
+	if scheme == "" {
+		cfg, err := r.repo.Config()
+		if err != nil {
+			return "", err
+		}
+		scheme = VersionScheme(cfg.Raw.Section(configSection).Option(versioningOption))
+	}
+
+	switch scheme {
+	case "":
+		return SemverScheme, nil
+	case SemverScheme, CounterScheme:
+		return scheme, nil
+	default:
+		return "", fmt.Errorf("unknown versioning scheme %q", scheme)
+	}
+}
+
+// nextVersionMessage returns the commit message for the next version of
+// path, numbered after its latest saved version. Only the versions since
+// the last one with a number are read, unless that number is of another
+// scheme and the versions have to be counted.
+func (r Repository) nextVersionMessage(path string) (string, error) {
+	scheme, err := r.VersionScheme()
+	if err != nil {
+		return "", err
+	}
+
+	file := filepath.ToSlash(filepath.Clean(path))
+	previous := ""
+	err = r.walkVersions(file, func(v Version) (bool, error) {
+		previous = v.Label
+		return previous == "", nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	version, ok := bumpVersion(scheme, previous)
+	if !ok {
+		saved := 0
+		err := r.walkVersions(file, func(Version) (bool, error) {
+			saved++
+			return true, nil
+		})
+		if err != nil {
+			return "", err
+		}
+		version = carryVersion(scheme, saved)
+	}
+	return r.message(path, version), nil
+}
+
+// versionMessage returns the commit message for the version of path that
+// follows versions, newest first, with any extra trailers
+func (r Repository) versionMessage(path string, versions []Version, trailers ...Trailer) (string, error) {
+	scheme, err := r.VersionScheme()
+	if err != nil {
+		return "", err
+	}
+
+	previous := ""
+	if len(versions) > 0 {
+		previous = versions[0].Label
+	}
+
+	return r.message(path, nextVersion(scheme, previous, len(versions)), trailers...), nil
+}
+
+// message returns the commit message of version of path
+func (r Repository) message(path, version string, trailers ...Trailer) string {
+	trailers = append([]Trailer{{versionTrailer, version}, {pathTrailer, path}}, trailers...)
+	return buildMessage(path+"-v"+version, trailers...)
+}
+
+// versionLabel returns the version recorded in a commit message for path,
+// falling back to the "<path>-v<version>" subject used before trailers
+func versionLabel(path, message string) string {
+	if version, ok := commitTrailer(message, versionTrailer); ok {
+		return version
+	}
+
+	version, ok := strings.CutPrefix(commitSubject(message), path+"-v")
+	if !ok {
+		return ""
+	}
+	return version
+}
+
+// nextVersion numbers the version following previous, which is empty for
+// the first version of a file, given how many versions were saved so far
+func nextVersion(scheme VersionScheme, previous string, saved int) string {
+	if version, ok := bumpVersion(scheme, previous); ok {
+		return version
+	}
+	return carryVersion(scheme, saved)
+}
+
+// bumpVersion numbers the version following previous. It reports false
+// when previous is not a number of scheme.
+func bumpVersion(scheme VersionScheme, previous string) (string, bool) {
+	switch scheme {
+	case CounterScheme:
+		n, err := strconv.Atoi(previous)
+		if err != nil {
+			return "", false
+		}
+		return strconv.Itoa(n + 1), true
+
+	default:
+		parts := strings.Split(previous, ".")
+		if len(parts) == 3 {
+			if patch, err := strconv.Atoi(parts[2]); err == nil {
+				return fmt.Sprintf("%s.%s.%d", parts[0], parts[1], patch+1), true
+			}
+		}
+		return "", false
+	}
+}
+
+// carryVersion numbers the version following saved versions that were
+// numbered with another scheme, or none at all. They still count.
+func carryVersion(scheme VersionScheme, saved int) string {
+	switch scheme {
+	case CounterScheme:
+		return strconv.Itoa(saved + 1)
+	default:
+		if saved == 0 {
+			return firstSemver
+		}
+		return fmt.Sprintf("0.1.%d", saved)
+	}
+}
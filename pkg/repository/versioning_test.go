@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+)
+
+func TestNextVersion(t *testing.T) {
+	for _, tc := range []struct {
+		scheme   VersionScheme
+		previous string
+		saved    int
+		want     string
+	}{
+		{SemverScheme, "", 0, "0.1.0"},
+		{SemverScheme, "0.1.0", 1, "0.1.1"},
+		{SemverScheme, "1.2.9", 12, "1.2.10"},
+		{CounterScheme, "", 0, "1"},
+		{CounterScheme, "1", 1, "2"},
+		{CounterScheme, "41", 41, "42"},
+		// Switching schemes carries on from the number of versions
+		{CounterScheme, "0.1.4", 5, "6"},
+		{SemverScheme, "7", 7, "0.1.7"},
+		// Versions saved before they were numbered
+		{CounterScheme, "", 3, "4"},
+		{SemverScheme, "", 3, "0.1.3"},
+	} {
+		if got := nextVersion(tc.scheme, tc.previous, tc.saved); got != tc.want {
+			t.Errorf("nextVersion(%s, %q, %d) = %s, want %s", tc.scheme, tc.previous, tc.saved, got, tc.want)
+		}
+	}
+}
+
+func TestSavedVersionsAreNumbered(t *testing.T) {
+	testUser(t)
+	r, dir := newClone(t, newRemote(t))
+
+	labels := func() []string {
+		t.Helper()
+		versions, err := r.FileVersions("main.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var labels []string
+		for _, v := range versions {
+			labels = append(labels, v.Label)
+		}
+		return labels
+	}
+
+	for i, step := range []struct {
+		versioning string
+		want       string
+	}{
+		{"", "0.1.0"},
+		{"", "0.1.1"},
+		{config.VersioningCounter, "3"},
+		{config.VersioningCounter, "4"},
+		{config.VersioningSemver, "0.1.4"},
+	} {
+		r.SetIntegration(config.Integration{Versioning: step.versioning})
+		save(t, r, dir, "main.go", "package main\n"+string(rune('a'+i))+"\n")
+		if got := labels()[0]; got != step.want {
+			t.Errorf("save %d with versioning %q numbered %s, want %s", i+1, step.versioning, got, step.want)
+		}
+	}
+}
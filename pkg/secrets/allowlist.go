@@ -0,0 +1,71 @@
+package secrets
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// AllowlistFile lists, at the project root, the files never scanned and the
+// secrets that are fine to version
+const AllowlistFile = ".ctrlsallow"
+
+var fingerprintLine = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// Allowlist holds the entries of an allowlist file. Each line is either a
+// fingerprint printed by ctrls scan or a .gitignore style pattern of files
+// to skip.
+type Allowlist struct {
+	paths        gitignore.Matcher
+	fingerprints map[string]bool
+}
+
+// LoadAllowlist reads the allowlist of the project at root. A missing file
+// allows nothing.
+func LoadAllowlist(root string) (*Allowlist, error) {
+	allow := &Allowlist{fingerprints: map[string]bool{}}
+
+	f, err := os.Open(filepath.Join(root, AllowlistFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return allow, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []gitignore.Pattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case fingerprintLine.MatchString(line):
+			allow.fingerprints[line] = true
+		default:
+			patterns = append(patterns, gitignore.ParsePattern(line, nil))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	allow.paths = gitignore.NewMatcher(patterns)
+	return allow, nil
+}
+
+// MatchPath reports whether the file at path, slash separated and relative
+// to the project root, is never scanned
+func (a *Allowlist) MatchPath(path string) bool {
+	return a != nil && a.paths != nil && a.paths.Match(strings.Split(path, "/"), false)
+}
+
+// MatchFingerprint reports whether the secret with fingerprint is allowed
+func (a *Allowlist) MatchFingerprint(fingerprint string) bool {
+	return a != nil && a.fingerprints[fingerprint]
+}
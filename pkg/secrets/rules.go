@@ -0,0 +1,115 @@
+package secrets
+
+import (
+	"math"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Rule finds one kind of secret. Only the group named secret is the secret
+// when the pattern has one, otherwise the whole match is.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// MinEntropy, when set, drops matches with less Shannon entropy per
+	// character, which is how random strings are told from words. Such
+	// rules also skip checksums, which are just as random.
+	MinEntropy float64
+	// SkipFiles are base names of files the rule does not look at
+	SkipFiles []string
+}
+
+// lockFiles hold nothing but checksums of dependencies
+var lockFiles = []string{
+	"go.sum", "go.work.sum",
+	"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml", "bun.lock",
+	"Cargo.lock", "Gemfile.lock", "composer.lock", "poetry.lock", "Pipfile.lock", "uv.lock",
+	"flake.lock", "mix.lock", "pubspec.lock", "Podfile.lock", "packages.lock.json", "gradle.lockfile",
+}
+
+var (
+	// checksumLabel ends the text before a checksum named by its
+	// algorithm, like h1: in go.sum
+	checksumLabel = regexp.MustCompile(`(?i)\b(?:h1|md5|sha1|sha224|sha256|sha384|sha512|blake2b|blake3):$`)
+	// checksumValue starts a checksum that carries its algorithm, like the
+	// integrity fields of package-lock.json
+	checksumValue = regexp.MustCompile(`(?i)^(?:md5|sha1|sha224|sha256|sha384|sha512)-`)
+	// hexDigest is a hash written in hex
+	hexDigest = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+)
+
+// minEntropy is about what 20 or more random base64 characters have, and
+// more than hex digests and identifiers do
+const minEntropy = 4.0
+
+// builtinRules are the secrets looked for in every project
+var builtinRules = []Rule{
+	{
+		Name:    "aws-access-key-id",
+		Pattern: regexp.MustCompile(`\b(?P<secret>(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16})\b`),
+	},
+	{
+		Name:    "aws-secret-access-key",
+		Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})`),
+	},
+	{
+		Name:    "github-token",
+		Pattern: regexp.MustCompile(`\b(?P<secret>(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`),
+	},
+	{
+		Name:    "private-key",
+		Pattern: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----[\s\S]*?-----END (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----`),
+	},
+	{
+		// Values assigned or quoted, like in .env files and configuration
+		Name:       "high-entropy-string",
+		Pattern:    regexp.MustCompile(`(?:[:=]\s*["'` + "`" + `]?|["'` + "`" + `])(?P<secret>[A-Za-z0-9+/_\-]{20,}={0,2})`),
+		MinEntropy: minEntropy,
+		SkipFiles:  lockFiles,
+	},
+}
+
+// skips reports whether rule does not look at the file at path
+func (rule Rule) skips(filePath string) bool {
+	name := path.Base(filePath)
+	for _, skip := range rule.SkipFiles {
+		if name == skip {
+			return true
+		}
+	}
+	return false
+}
+
+// checksum reports whether secret, found in content at start, is a hash
+// rather than a secret
+func checksum(content []byte, start int, secret string) bool {
+	if hexDigest.MatchString(secret) || checksumValue.MatchString(secret) {
+		return true
+	}
+	return checksumLabel.Match(content[max(0, start-16):start])
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, c := range s {
+		counts[c]++
+	}
+
+	var bits float64
+	n := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / n
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// random reports whether s looks like random data rather than a word or a
+// path, having enough entropy and both letters and digits
+func random(s string, min float64) bool {
+	return entropy(s) >= min &&
+		strings.ContainsAny(s, "0123456789") &&
+		strings.IndexFunc(s, func(c rune) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }) >= 0
+}
@@ -0,0 +1,181 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+)
+
+// ErrSecretFound is returned for files that hold what looks like a secret
+// when the scanner is set to block them
+var ErrSecretFound = errors.New("file looks like it holds a secret")
+
+// binaryProbe is how much of a file is checked for NUL bytes, like git does
+const binaryProbe = 8000
+
+// Finding is a possible secret found in a file
+type Finding struct {
+	Path   string `json:"path"`
+	Rule   string `json:"rule"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	// Fingerprint names the secret in the allowlist without revealing it
+	Fingerprint string `json:"fingerprint"`
+	// Preview is the start of the secret, enough to recognize it
+	Preview string `json:"preview"`
+
+	start, end int
+}
+
+// Scanner looks for secrets in the content of files
+type Scanner struct {
+	action string
+	rules  []Rule
+	allow  *Allowlist
+}
+
+// New creates a Scanner with the built-in rules plus the ones in cfg, which
+// must have been validated, skipping what allow lists
+func New(cfg config.Secrets, allow *Allowlist) *Scanner {
+	rules := append([]Rule{}, builtinRules...)
+	for _, rule := range cfg.Rules {
+		rules = append(rules, Rule{Name: rule.Name, Pattern: regexp.MustCompile(rule.Pattern)})
+	}
+
+	action := cfg.Action
+	if action == "" {
+		action = config.SecretsBlock
+	}
+	return &Scanner{action: action, rules: rules, allow: allow}
+}
+
+// Action returns what to do with files that have findings, one of the
+// config.Secrets constants. A nil Scanner is off.
+func (s *Scanner) Action() string {
+	if s == nil {
+		return config.SecretsOff
+	}
+	return s.action
+}
+
+// Scan returns the secrets in content, the content of path, in order.
+// Binary files and allowlisted paths and secrets are skipped.
+func (s *Scanner) Scan(path string, content []byte) []Finding {
+	if s == nil || s.allow.MatchPath(path) || bytes.IndexByte(content[:min(len(content), binaryProbe)], 0) >= 0 {
+		return nil
+	}
+
+	var findings []Finding
+	for _, rule := range s.rules {
+		if rule.skips(path) {
+			continue
+		}
+
+		group := rule.Pattern.SubexpIndex("secret")
+		for _, match := range rule.Pattern.FindAllSubmatchIndex(content, -1) {
+			start, end := match[0], match[1]
+			if group > 0 {
+				start, end = match[2*group], match[2*group+1]
+			}
+			if start < 0 || start == end {
+				continue
+			}
+
+			secret := string(content[start:end])
+			if rule.MinEntropy > 0 && (!random(secret, rule.MinEntropy) || checksum(content, start, secret)) {
+				continue
+			}
+
+			finding := Finding{
+				Path:        path,
+				Rule:        rule.Name,
+				Fingerprint: fingerprint(secret),
+				Preview:     preview(secret),
+				start:       start,
+				end:         end,
+			}
+			if s.allow.MatchFingerprint(finding.Fingerprint) {
+				continue
+			}
+			finding.Line = bytes.Count(content[:start], []byte("\n")) + 1
+			finding.Column = start - bytes.LastIndexByte(content[:start], '\n')
+			findings = append(findings, finding)
+		}
+	}
+
+	return dropOverlaps(findings)
+}
+
+// Redact returns content with every finding replaced by a marker naming
+// its rule. findings must come from Scan on the same content.
+func Redact(content []byte, findings []Finding) []byte {
+	var b bytes.Buffer
+	last := 0
+	for _, finding := range findings {
+		b.Write(content[last:finding.start])
+		fmt.Fprintf(&b, "[REDACTED:%s]", finding.Rule)
+		last = finding.end
+	}
+	b.Write(content[last:])
+	return b.Bytes()
+}
+
+// Error is returned with ErrSecretFound, listing what was found
+type Error struct {
+	Path     string
+	Findings []Finding
+}
+
+func (e *Error) Error() string {
+	var found []string
+	for _, finding := range e.Findings {
+		found = append(found, fmt.Sprintf("%s on line %d", finding.Rule, finding.Line))
+	}
+	return fmt.Sprintf("%s: %s (%s)", e.Path, ErrSecretFound, strings.Join(found, ", "))
+}
+
+func (e *Error) Unwrap() error {
+	return ErrSecretFound
+}
+
+// dropOverlaps sorts findings by position and keeps the first of the ones
+// that overlap, so that a secret is reported and redacted once
+func dropOverlaps(findings []Finding) []Finding {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].start < findings[j].start
+	})
+
+	kept := findings[:0]
+	end := -1
+	for _, finding := range findings {
+		if finding.start < end {
+			continue
+		}
+		kept = append(kept, finding)
+		end = finding.end
+	}
+	return kept
+}
+
+// fingerprint names a secret by a hash of its value, so that whichever rule
+// finds it the same allowlist entry applies
+func fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:8])
+}
+
+// preview shows the first characters of secret
+func preview(secret string) string {
+	secret, _, _ = strings.Cut(secret, "\n")
+	if len(secret) > 8 {
+		secret = secret[:8]
+	}
+	return secret + "…"
+}
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ConfigDir returns the directory for per-user versionctrls files,
+// $XDG_CONFIG_HOME/versionctrls or ~/.config/versionctrls
+func ConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "versionctrls"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "versionctrls"), nil
+}
@@ -3,22 +3,61 @@ package utils
 import (
 	"io"
 	"os"
+	"path/filepath"
+	"time"
 )
 
-// CopyFile copies a file from src to dst
+// CopyFile copies a file from src to dst, keeping its permissions and
+// modification time. Symlinks are copied as symlinks rather than followed.
+// The copy is written to a temporary file next to dst and renamed into
+// place, so dst is never left half written.
 func CopyFile(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		// The time of the link itself cannot be set portably, and setting
+		// it through the link would touch its target
+		return WriteSymlinkAtomic(dst, target)
+	}
+
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(dst)
+	tmp, err := os.CreateTemp(filepath.Dir(dst), tempPattern(dst))
 	if err != nil {
 		return err
 	}
-	defer dstFile.Close()
+	tmpPath := tmp.Name()
 
-	_, err = io.Copy(dstFile, srcFile)
+	_, err = io.Copy(tmp, srcFile)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, info.Mode().Perm())
+	}
+	if err == nil {
+		// A zero access time is left as it is
+		err = os.Chtimes(tmpPath, time.Time{}, info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, dst)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
 	return err
 }
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyFile(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+
+	script := filepath.Join(src, "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2024, 5, 1, 14, 32, 10, 0, time.UTC)
+	if err := os.Chtimes(script, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("run.sh", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"run.sh", "link"} {
+		if err := CopyFile(filepath.Join(src, name), filepath.Join(dst, name)); err != nil {
+			t.Fatalf("CopyFile(%s): %v", name, err)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0755 {
+		t.Errorf("copy has mode %v, want %v", perm, os.FileMode(0755))
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("copy was modified at %v, want %v", info.ModTime(), modTime)
+	}
+
+	link := filepath.Join(dst, "link")
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("link was not copied as a symlink: %v", err)
+	}
+	if target, err := os.Readlink(link); err != nil || target != "run.sh" {
+		t.Errorf("copied link points at %q, %v, want run.sh", target, err)
+	}
+
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if IsTempFile(e.Name()) {
+			t.Errorf("temporary file %s left behind", e.Name())
+		}
+	}
+}
+
+func TestIsTempFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		".a.txt.tmp-123":     true,
+		"dir/.a.txt.tmp-987": true,
+		"a.txt.tmp-123":      false,
+		".a.tmp-x":           false,
+		".a.tmp-":            false,
+		".a.txt":             false,
+	} {
+		if got := IsTempFile(name); got != want {
+			t.Errorf("IsTempFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"15:04:05",
+	"15:04",
+}
+
+var relativeUnits = map[string]time.Duration{
+	"s":      time.Second,
+	"sec":    time.Second,
+	"second": time.Second,
+	"m":      time.Minute,
+	"min":    time.Minute,
+	"minute": time.Minute,
+	"h":      time.Hour,
+	"hr":     time.Hour,
+	"hour":   time.Hour,
+	"d":      24 * time.Hour,
+	"day":    24 * time.Hour,
+	"w":      7 * 24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+	"yr":     365 * 24 * time.Hour,
+}
+
+// ParseTime parses an absolute timestamp such as "2024-05-01 14:32" or a
+// relative one such as "2h ago", "3 days ago" or "yesterday", relative to now
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	// Keywords and units are matched in any case, while the layouts need
+	// the "T" and "Z" of RFC 3339 as they are
+	s = strings.TrimSpace(s)
+	lower := strings.ToLower(s)
+
+	switch lower {
+	case "now":
+		return now, nil
+	case "today":
+		y, m, d := now.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	case "yesterday":
+		y, m, d := now.AddDate(0, 0, -1).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	}
+
+	if rest, ok := strings.CutSuffix(lower, "ago"); ok {
+		d, err := parseRelative(strings.TrimSpace(rest))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(layout, "2006") {
+			// Times of day refer to today
+			y, m, d := now.Date()
+			t = time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+		}
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("could not parse time %q", s)
+}
+
+// parseRelative parses durations such as "2h", "90m", "2 hours" or "1 day 3h"
+func parseRelative(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(strings.ReplaceAll(s, " ", "")); err == nil {
+		return d, nil
+	}
+
+	var total time.Duration
+	fields := strings.Fields(s)
+	for i := 0; i < len(fields); i++ {
+		number, unit := splitNumber(fields[i])
+		if unit == "" && i+1 < len(fields) {
+			i++
+			unit = fields[i]
+		}
+
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse duration %q", s)
+		}
+
+		d, ok := relativeUnits[unit]
+		if !ok {
+			d, ok = relativeUnits[strings.TrimSuffix(unit, "s")]
+		}
+		if !ok {
+			return 0, fmt.Errorf("unknown time unit %q", unit)
+		}
+		total += time.Duration(n) * d
+	}
+
+	if total == 0 {
+		return 0, fmt.Errorf("could not parse duration %q", s)
+	}
+	return total, nil
+}
+
+// splitNumber splits "2h" into "2" and "h"
+func splitNumber(s string) (string, string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i], s[i:]
+}
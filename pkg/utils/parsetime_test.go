@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	loc := time.FixedZone("UTC-3", -3*60*60)
+	now := time.Date(2024, 5, 10, 15, 30, 0, 0, loc)
+
+	for _, tc := range []struct {
+		in   string
+		want time.Time
+	}{
+		{"now", now},
+		{"  Yesterday ", time.Date(2024, 5, 9, 0, 0, 0, 0, loc)},
+		{"2h ago", now.Add(-2 * time.Hour)},
+		{"3 Days Ago", now.Add(-72 * time.Hour)},
+		{"2024-05-01T14:32:00Z", time.Date(2024, 5, 1, 14, 32, 0, 0, time.UTC)},
+		{"2024-05-01T14:32:00+02:00", time.Date(2024, 5, 1, 12, 32, 0, 0, time.UTC)},
+		{"2024-05-01T14:32:10", time.Date(2024, 5, 1, 14, 32, 10, 0, loc)},
+		{"2024-05-01T14:32", time.Date(2024, 5, 1, 14, 32, 0, 0, loc)},
+		{"2024-05-01 14:32", time.Date(2024, 5, 1, 14, 32, 0, 0, loc)},
+		{"2024-05-01", time.Date(2024, 5, 1, 0, 0, 0, 0, loc)},
+		{"09:15", time.Date(2024, 5, 10, 9, 15, 0, 0, loc)},
+	} {
+		got, err := ParseTime(tc.in, now)
+		if err != nil {
+			t.Errorf("ParseTime(%q): %v", tc.in, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("ParseTime(%q) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+
+	for _, in := range []string{"", "soon", "2 fortnights ago", "2024-13-01"} {
+		if got, err := ParseTime(in, now); err == nil {
+			t.Errorf("ParseTime(%q) = %s, want an error", in, got)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafePath is returned for paths that would lead outside the project
+// or into its .git directory
+var ErrUnsafePath = errors.New("path is outside the project")
+
+// ProjectPath returns where the file at the slash separated path lives in
+// the project at root. Paths of saved versions may come from a shared
+// remote, so like git it refuses absolute paths, ".." and ".git"
+// components, and paths that go through a symlinked directory.
+func ProjectPath(root, path string) (string, error) {
+	local := filepath.FromSlash(path)
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("%q: %w", path, ErrUnsafePath)
+	}
+
+	parts := strings.Split(filepath.ToSlash(local), "/")
+	for _, part := range parts {
+		if part == ".." || strings.EqualFold(part, ".git") {
+			return "", fmt.Errorf("%q: %w", path, ErrUnsafePath)
+		}
+	}
+
+	full := filepath.Join(root, local)
+	if rel, err := filepath.Rel(root, full); err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%q: %w", path, ErrUnsafePath)
+	}
+
+	dir := root
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("%q is beyond a symbolic link: %w", path, ErrUnsafePath)
+		}
+	}
+
+	return full, nil
+}
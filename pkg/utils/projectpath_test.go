@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectPath(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(t.TempDir(), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"main.go", "dir/file.txt", "new/dir/file.txt", "a..b", ".gitignore", "dir/.github/x"} {
+		got, err := ProjectPath(root, path)
+		if err != nil {
+			t.Errorf("ProjectPath(%q): %v", path, err)
+			continue
+		}
+		if want := filepath.Join(root, filepath.FromSlash(path)); got != want {
+			t.Errorf("ProjectPath(%q) = %s, want %s", path, got, want)
+		}
+	}
+
+	for _, path := range []string{
+		"",
+		".",
+		"..",
+		"../outside",
+		"dir/../../outside",
+		"dir/../file",
+		"/etc/passwd",
+		".git/hooks/post-checkout",
+		"dir/.GIT/config",
+		"link/file",
+	} {
+		if got, err := ProjectPath(root, path); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("ProjectPath(%q) = %q, %v, want ErrUnsafePath", path, got, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package utils
+
+import "os"
+
+// ReadFileOrLink returns the content of the file at path or, for a symlink,
+// its target, which is what git records as the content of a link
+func ReadFileOrLink(path string) ([]byte, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(target), nil
+	}
+
+	return os.ReadFile(path)
+}
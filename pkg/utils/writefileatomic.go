@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempInfix comes between the name of a file and the random digits of the
+// temporary file it is written to first
+const tempInfix = ".tmp-"
+
+// tempPattern returns the os.CreateTemp pattern of the temporary file for
+// path, a hidden file next to it
+func tempPattern(path string) string {
+	return "." + filepath.Base(path) + tempInfix + "*"
+}
+
+// IsTempFile reports whether path is a temporary file of the atomic writes
+// in this package, which a crash may leave behind
+func IsTempFile(path string) bool {
+	name := filepath.Base(path)
+	i := strings.LastIndex(name, tempInfix)
+	if !strings.HasPrefix(name, ".") || i < 0 {
+		return false
+	}
+
+	digits := name[i+len(tempInfix):]
+	return digits != "" && strings.Trim(digits, "0123456789") == ""
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers see either the old or the new content in full
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPattern(path))
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
+// WriteSymlinkAtomic points path at target, replacing whatever is at path
+// in a single rename
+func WriteSymlinkAtomic(path, target string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPattern(path))
+	if err != nil {
+		return err
+	}
+	// Only the unique name is needed, a symlink cannot be created over it
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
+
+	err = os.Symlink(target, tmpPath)
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
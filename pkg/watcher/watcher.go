@@ -0,0 +1,304 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher reports files under a root directory once writes to them settle
+type Watcher struct {
+	root     string
+	debounce time.Duration
+	skip     map[string]bool
+	ignored  func(path string, isDir bool) bool
+	fsw      *fsnotify.Watcher
+
+	mu      sync.Mutex
+	pending map[string]*time.Timer
+	// settled collects the paths of the next batch, which is ready when
+	// nothing is pending or wait fires
+	settled []string
+	rescan  bool
+	wait    *time.Timer
+	ready   chan struct{}
+}
+
+// Batch is the files that settled together, one write following another
+// before the debounce interval was over. Paths are relative to the root.
+type Batch struct {
+	// Saved are the files that were written and still exist
+	Saved []string
+	// Removed are the files that were deleted or moved away
+	Removed []string
+	// Rescan is set when events were lost, so any file may have changed
+	Rescan bool
+}
+
+// New creates a Watcher for every directory under root, leaving out .git,
+// the given directories relative to root and whatever ignored reports. ignored
+// gets slash separated paths relative to root and may be nil.
+func New(root string, debounce time.Duration, ignored func(path string, isDir bool) bool, skip ...string) (*Watcher, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		root:     root,
+		debounce: debounce,
+		skip:     map[string]bool{},
+		ignored:  ignored,
+		fsw:      fsw,
+		pending:  map[string]*time.Timer{},
+		ready:    make(chan struct{}, 1),
+	}
+	for _, dir := range skip {
+		w.skip[filepath.Clean(dir)] = true
+	}
+
+	if err := w.addTree(root); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Run calls onBatch with every batch of files that were written and then
+// left alone for the debounce interval, or deleted. A batch waits at most
+// another debounce interval for files that keep being written. When the
+// system drops events, a batch with Rescan set follows. Other errors are
+// passed to onError, when not nil, and watching goes on. Calls are made one
+// at a time, so neither needs to be safe for concurrent use.
+func (w *Watcher) Run(ctx context.Context, onBatch func(Batch), onError func(error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// Directories created meanwhile may be missing a watch too
+				err = w.addTree(w.root)
+				w.mu.Lock()
+				w.rescan = true
+				w.signal()
+				w.mu.Unlock()
+			}
+			if err != nil && onError != nil {
+				onError(err)
+			}
+
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+
+		case <-w.ready:
+			if batch := w.takeBatch(); len(batch.Saved)+len(batch.Removed) > 0 || batch.Rescan {
+				onBatch(batch)
+			}
+		}
+	}
+}
+
+// SetIgnored replaces the function that reports what to leave out, as
+// given to New, and watches the directories it no longer leaves out. It
+// is meant to be called from onBatch.
+func (w *Watcher) SetIgnored(ignored func(path string, isDir bool) bool) error {
+	w.ignored = ignored
+	return w.addTree(w.root)
+}
+
+// Close stops watching and drops any saves still being debounced
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	for path, timer := range w.pending {
+		timer.Stop()
+		delete(w.pending, path)
+	}
+	if w.wait != nil {
+		w.wait.Stop()
+	}
+	w.mu.Unlock()
+
+	return w.fsw.Close()
+}
+
+// takeBatch returns the files settled so far and starts the next batch
+func (w *Watcher) takeBatch() Batch {
+	w.mu.Lock()
+	paths := w.settled
+	batch := Batch{Rescan: w.rescan}
+	w.settled = nil
+	w.rescan = false
+	if w.wait != nil {
+		w.wait.Stop()
+		w.wait = nil
+	}
+	w.mu.Unlock()
+
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		// Editors that save by renaming a temp file over the original
+		// leave nothing behind for the temp path, so files are only
+		// reported as saved when they still exist once things have settled
+		info, err := os.Lstat(filepath.Join(w.root, path))
+		if os.IsNotExist(err) {
+			batch.Removed = append(batch.Removed, path)
+			continue
+		}
+		if err != nil || (!info.Mode().IsRegular() && info.Mode()&os.ModeSymlink == 0) {
+			continue
+		}
+		batch.Saved = append(batch.Saved, path)
+	}
+	return batch
+}
+
+func (w *Watcher) handle(event fsnotify.Event) {
+	rel, err := filepath.Rel(w.root, event.Name)
+	if err != nil || w.skipped(rel) {
+		return
+	}
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		// Whether the file is really gone is checked once things settle
+		if !w.ignore(rel, false) {
+			w.schedule(rel)
+		}
+		return
+	}
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+
+	info, err := os.Lstat(event.Name)
+	if err != nil {
+		return
+	}
+
+	if info.IsDir() {
+		// New directories need their own watch, and anything written into
+		// them before the watch was added would otherwise be missed
+		_ = w.addTree(event.Name)
+		_ = filepath.WalkDir(event.Name, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && (d.Type().IsRegular() || d.Type()&fs.ModeSymlink != 0) {
+				if rel, err := filepath.Rel(w.root, path); err == nil && !w.skipped(rel) && !w.ignore(rel, false) {
+					w.schedule(rel)
+				}
+			}
+			return nil
+		})
+		return
+	}
+
+	if !w.ignore(rel, false) {
+		w.schedule(rel)
+	}
+}
+
+// schedule restarts the debounce timer for path
+func (w *Watcher) schedule(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if timer, ok := w.pending[path]; ok {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(w.debounce, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.pending[path] != timer {
+			// A later write restarted the debounce for this path
+			return
+		}
+		delete(w.pending, path)
+		w.settle(path)
+	})
+	w.pending[path] = timer
+}
+
+// settle adds path to the next batch, which is ready once nothing else is
+// pending, or a debounce interval after its first file settled. w.mu must
+// be held.
+func (w *Watcher) settle(path string) {
+	w.settled = append(w.settled, path)
+	if len(w.pending) == 0 {
+		w.signal()
+		return
+	}
+	if w.wait == nil {
+		w.wait = time.AfterFunc(w.debounce, w.signal)
+	}
+}
+
+// signal tells Run a batch is ready, unless it was told already
+func (w *Watcher) signal() {
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+}
+
+// addTree watches dir and every directory below it that is not skipped
+func (w *Watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(w.root, path)
+		if err != nil {
+			return err
+		}
+		if w.skipped(rel) || w.ignore(rel, true) {
+			return filepath.SkipDir
+		}
+
+		return w.fsw.Add(path)
+	})
+}
+
+// skipped reports whether rel is .git, one of the skipped directories, or
+// inside either
+func (w *Watcher) skipped(rel string) bool {
+	for rel != "." && rel != string(filepath.Separator) && rel != "" {
+		if filepath.Base(rel) == ".git" || w.skip[rel] {
+			return true
+		}
+		rel = filepath.Dir(rel)
+	}
+	return false
+}
+
+// ignore reports whether the caller wants rel left out
+func (w *Watcher) ignore(rel string, isDir bool) bool {
+	return w.ignored != nil && rel != "." && w.ignored(filepath.ToSlash(rel), isDir)
+}
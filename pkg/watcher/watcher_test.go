@@ -0,0 +1,100 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// run starts w and returns the batches it reports
+func run(t *testing.T, w *Watcher) <-chan Batch {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := make(chan Batch, 16)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(ctx, func(b Batch) { batches <- b }, func(err error) { t.Error(err) })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		w.Close()
+	})
+	return batches
+}
+
+func write(t *testing.T, root, file, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(root, file), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func next(t *testing.T, batches <-chan Batch) Batch {
+	t.Helper()
+	select {
+	case b := <-batches:
+		sort.Strings(b.Saved)
+		sort.Strings(b.Removed)
+		return b
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch")
+		return Batch{}
+	}
+}
+
+func TestRunBatches(t *testing.T) {
+	root := t.TempDir()
+	write(t, root, "gone.txt", "x")
+
+	w, err := New(root, 100*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches := run(t, w)
+
+	write(t, root, "a.txt", "a")
+	write(t, root, "b.txt", "b")
+	if err := os.Remove(filepath.Join(root, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Batch{Saved: []string{"a.txt", "b.txt"}, Removed: []string{"gone.txt"}}
+	if got := next(t, batches); !reflect.DeepEqual(got, want) {
+		t.Errorf("first batch = %+v, want %+v", got, want)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	write(t, root, "a.txt", "again")
+	want = Batch{Saved: []string{"a.txt"}}
+	if got := next(t, batches); !reflect.DeepEqual(got, want) {
+		t.Errorf("second batch = %+v, want %+v", got, want)
+	}
+}
+
+func TestSetIgnored(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(root, 100*time.Millisecond, func(path string, isDir bool) bool { return path == "build" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetIgnored(nil); err != nil {
+		t.Fatal(err)
+	}
+	batches := run(t, w)
+
+	write(t, root, "build/out.txt", "out")
+	want := Batch{Saved: []string{"build/out.txt"}}
+	if got := next(t, batches); !reflect.DeepEqual(got, want) {
+		t.Errorf("batch = %+v, want %+v", got, want)
+	}
+}
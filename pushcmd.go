@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+)
+
+// runPush uploads every snapshot to the integration remote, or with --retry
+// only the queued ones whose backoff delay is over
+func runPush(args []string) {
+	flags := flag.NewFlagSet("push", flag.ExitOnError)
+	remote := flags.String("remote", repository.DefaultRemote, "remote of the integration repository to push to")
+	retry := flags.Bool("retry", false, "only push versions queued while the remote was unavailable")
+	force := flags.Bool("force", false, "replace the versions on the remote, after ctrls key rotate rewrote them")
+	flags.Parse(args)
+
+	if *retry && *force {
+		fmt.Println("Usage: ctrls push [--remote <name>] [--retry | --force]")
+		os.Exit(2)
+	}
+
+	_, vRepo := openRepositories()
+	auth := remoteAuth(vRepo, *remote)
+
+	var results []repository.RefSyncResult
+	var err error
+	if *retry {
+		results, err = vRepo.RetryOutbox(*remote, auth, false)
+	} else {
+		results, err = vRepo.PushSnapshots(*remote, auth, *force)
+	}
+	if err != nil {
+		fmt.Println("Error pushing snapshots:", err)
+		os.Exit(1)
+	}
+
+	if *retry && len(results) == 0 {
+		fmt.Println("Nothing to retry yet.")
+		return
+	}
+
+	ok := reportSync(results, "Pushed")
+
+	// Versions of large files stored with the lfs strategy point at objects
+	// that git lfs uploads separately
+	objects, err := vRepo.PushLFSObjects(*remote)
+	if err != nil {
+		fmt.Println("Error pushing LFS objects:", err)
+		ok = false
+	} else if objects > 0 {
+		fmt.Printf("Synced %d LFS %s with the remote\n", objects, plural(objects, "object"))
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// runPull downloads snapshots saved elsewhere from the integration remote
+func runPull(args []string) {
+	flags := flag.NewFlagSet("pull", flag.ExitOnError)
+	remote := flags.String("remote", repository.DefaultRemote, "remote of the integration repository to pull from")
+	flags.Parse(args)
+
+	_, vRepo := openRepositories()
+	auth := remoteAuth(vRepo, *remote)
+
+	results, err := vRepo.PullSnapshots(*remote, auth)
+	if err != nil {
+		fmt.Println("Error pulling snapshots:", err)
+		os.Exit(1)
+	}
+
+	if !reportSync(results, "Pulled") {
+		os.Exit(1)
+	}
+}
+
+// remoteAuth resolves how to authenticate against the integration remote
+func remoteAuth(vRepo *repository.Repository, remote string) transport.AuthMethod {
+	auth, err := vRepo.RemoteAuth(remote, credentialStore())
+	if err != nil {
+		fmt.Println("Error preparing authentication:", err)
+		os.Exit(1)
+	}
+	return auth
+}
+
+// reportSync prints one line per reference that changed or failed and a
+// summary, reporting whether everything succeeded
+func reportSync(results []repository.RefSyncResult, verb string) bool {
+	updated, upToDate, failed := 0, 0, 0
+	for _, result := range results {
+		name := result.Path
+		if name == "" {
+			name = result.Ref.String()
+		}
+
+		switch {
+		case result.Err != nil:
+			failed++
+			fmt.Printf("Failed %s: %v\n", name, result.Err)
+		case result.UpToDate:
+			upToDate++
+		default:
+			updated++
+			fmt.Printf("%s %s\n", verb, name)
+		}
+	}
+
+	fmt.Printf("\n%d updated, %d up to date, %d failed\n", updated, upToDate, failed)
+	return failed == 0
+}
+
+// autoPushAuth returns whether snapshots are pushed as they are taken and
+// the credentials to push them with. It is called once when watching
+// starts, so that the terminal is never needed afterwards.
+func autoPushAuth(vRepo *repository.Repository) (bool, transport.AuthMethod) {
+	enabled, err := vRepo.AutoPushEnabled()
+	if err != nil || !enabled {
+		return false, nil
+	}
+
+	auth, err := vRepo.RemoteAuth(repository.DefaultRemote, credentialStore())
+	if err != nil {
+		log.Printf("Error reading credentials, snapshots stay queued until ctrls push: %v\n", err)
+		return false, nil
+	}
+	return true, auth
+}
+
+// autoPush pushes the queued snapshots with auth. Versions that cannot be
+// pushed stay queued and are retried with backoff. Errors are only logged.
+func autoPush(vRepo *repository.Repository, auth transport.AuthMethod) {
+	results, err := vRepo.RetryOutbox(repository.DefaultRemote, auth, false)
+	if err != nil {
+		log.Printf("Error pushing snapshots, will retry: %v\n", err)
+		return
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			log.Printf("Error pushing %s, will retry: %v\n", result.Path, result.Err)
+		} else if !result.UpToDate {
+			log.Printf("Pushed %s\n", result.Path)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// runRecover lists the saved files that are gone from the worktree, and
+// writes back the ones matching the given paths, directories or glob
+// patterns
+func runRecover(args []string) {
+	flags := flag.NewFlagSet("recover", flag.ExitOnError)
+	version := flags.String("version", "", "version to recover, by version number or commit hash, for a single file")
+	at := flags.String("at", "", "recover the versions saved at this time, e.g. \"2h ago\" or \"2024-05-01 14:32\"")
+	dryRun := flags.Bool("dry-run", false, "list the files that would be recovered without writing them")
+	asJSON := flags.Bool("json", false, "print the deleted files as JSON")
+	patterns := parseInterspersed(flags, args)
+
+	if *version != "" && *at != "" {
+		fmt.Println("Usage: ctrls recover [<path|dir|'glob'>...] [--version <version|hash> | --at <time>] [--dry-run] [--json]")
+		os.Exit(1)
+	}
+
+	repo, vRepo := openRepositories()
+
+	rootPath, err := repo.GetRepoRoot()
+	if err != nil {
+		fmt.Println("You are not in the root of the Git repository.")
+		os.Exit(1)
+	}
+
+	deleted, err := deletedFiles(vRepo, rootPath)
+	if err != nil {
+		fmt.Println("Error reading saved files:", err)
+		os.Exit(1)
+	}
+
+	if len(patterns) == 0 {
+		if *asJSON {
+			printJSON(deleted)
+			return
+		}
+		printDeleted(deleted)
+		return
+	}
+
+	var matched []repository.SavedFile
+	for _, pattern := range patterns {
+		found := false
+		for _, file := range deleted {
+			if matchesPattern(file.Path, pattern) {
+				found = true
+				if !containsFile(matched, file.Path) {
+					matched = append(matched, file)
+				}
+			}
+		}
+		if !found {
+			fmt.Printf("No deleted file matches %s\n", pattern)
+			os.Exit(1)
+		}
+	}
+
+	if *version != "" && len(matched) > 1 {
+		fmt.Printf("--version picks the version of a single file, but %d files match.\n", len(matched))
+		os.Exit(1)
+	}
+
+	// Paths come from saved versions, which may have been pulled from a
+	// shared remote
+	dsts := make([]string, len(matched))
+	for i, file := range matched {
+		dsts[i], err = utils.ProjectPath(rootPath, file.Path)
+		if err != nil {
+			fmt.Printf("Refusing to recover %s: %v\n", file.Path, err)
+			os.Exit(1)
+		}
+	}
+
+	for i, file := range matched {
+		target, err := selectVersion(vRepo, file.Path, *version, *at)
+		if err != nil {
+			fmt.Printf("Error finding version of %s: %v\n", file.Path, err)
+			os.Exit(1)
+		}
+
+		if *dryRun {
+			fmt.Printf("Would recover %s at %s %s\n", file.Path, versionName(target), target.Hash[:7])
+			continue
+		}
+
+		err = vRepo.WriteVersion(target, dsts[i])
+		if err != nil {
+			fmt.Printf("Error recovering %s: %v\n", file.Path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Recovered %s at %s %s (%s)\n", file.Path, versionName(target), target.Hash[:7], target.When.Local().Format("2006-01-02 15:04:05"))
+	}
+}
+
+// deletedFiles returns the saved files missing from the worktree at
+// rootPath, leaving out files that were renamed to a path that still exists
+func deletedFiles(vRepo *repository.Repository, rootPath string) ([]repository.SavedFile, error) {
+	files, err := vRepo.SavedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := []repository.SavedFile{}
+	for _, file := range files {
+		if exists(rootPath, file.Path) {
+			continue
+		}
+		if file.RenamedTo != "" && exists(rootPath, file.RenamedTo) {
+			continue
+		}
+		deleted = append(deleted, file)
+	}
+	return deleted, nil
+}
+
+// exists reports whether file is in the worktree at rootPath
+func exists(rootPath, file string) bool {
+	_, err := os.Lstat(filepath.Join(rootPath, filepath.FromSlash(file)))
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// printDeleted writes the deleted files as an aligned table
+func printDeleted(files []repository.SavedFile) {
+	if len(files) == 0 {
+		fmt.Println("No deleted files to recover.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, file := range files {
+		deleted := "deletion not recorded"
+		if !file.Deleted.IsZero() {
+			deleted = "deleted " + file.Deleted.Local().Format("2006-01-02 15:04:05")
+		}
+		if file.RenamedTo != "" {
+			deleted += ", was renamed to " + file.RenamedTo
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", file.Path, versionName(file.Latest), file.Latest.Hash[:7], deleted)
+	}
+	w.Flush()
+
+	fmt.Println("\nRun ctrls recover <path|dir|'glob'> to restore them.")
+}
+
+// matchesPattern reports whether file is pattern, lies in the directory
+// pattern, or matches pattern as a glob either itself or through one of its
+// parent directories
+func matchesPattern(file, pattern string) bool {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	if pattern == "." {
+		return true
+	}
+
+	for p := file; p != "." && p != "/"; p = path.Dir(p) {
+		if p == pattern {
+			return true
+		}
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFile reports whether files holds the file at path
+func containsFile(files []repository.SavedFile, path string) bool {
+	for _, file := range files {
+		if file.Path == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/encryption"
+	"github.com/renatonmag/versionctrls-cli/pkg/ignore"
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"github.com/renatonmag/versionctrls-cli/pkg/secrets"
+)
+
+// openProject opens the repository in the current directory and points it
+// at the integration submodule from its configuration, exiting with a
+// message if either fails
+func openProject() (*repository.Repository, config.Config) {
+	repo := repository.New()
+	err := repo.PlainOpen(".")
+	if err != nil {
+		fmt.Println("You are not in a Git repository.")
+		os.Exit(1)
+	}
+
+	rootPath, err := repo.GetRepoRoot()
+	if err != nil {
+		fmt.Println("You are not in the root of the Git repository.")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(rootPath)
+	if err != nil {
+		fmt.Println("Error reading configuration:", err)
+		os.Exit(1)
+	}
+	repo.SetSubmodulePath(cfg.Integration.Path)
+	repo.SetLargeFiles(cfg.LargeFiles)
+
+	matcher, err := ignore.Load(rootPath, cfg.Ignore.Presets)
+	if err != nil {
+		fmt.Println("Error reading ignore rules:", err)
+		os.Exit(1)
+	}
+	repo.SetIgnore(matcher)
+
+	allow, err := secrets.LoadAllowlist(rootPath)
+	if err != nil {
+		fmt.Println("Error reading secrets allowlist:", err)
+		os.Exit(1)
+	}
+	repo.SetSecrets(secrets.New(cfg.Secrets, allow))
+
+	keys, err := encryption.Load(cfg.Encryption, encryptionPassphrase)
+	if err != nil {
+		fmt.Println("Error reading encryption keys:", err)
+		os.Exit(1)
+	}
+	repo.SetEncryption(keys)
+
+	return repo, cfg
+}
+
+// openRepositories opens the repository in the current directory and its
+// integration submodule, exiting with a message if either is missing
+func openRepositories() (*repository.Repository, *repository.Repository) {
+	repo, cfg := openProject()
+	matcher := repo.IgnoreMatcher()
+
+	vPath, err := repo.IntegrationSubmodulePath()
+	if err != nil {
+		log.Fatalf("Error getting integration submodule path: %v", err)
+	}
+
+	vRepo := repository.New()
+	err = vRepo.PlainOpen(vPath)
+	if err != nil {
+		log.Fatalf("Error opening integration submodule: %v", err)
+	}
+	vRepo.SetIntegration(cfg.Integration)
+	vRepo.SetLargeFiles(cfg.LargeFiles)
+	// Copies in the submodule have the same paths as in the project
+	vRepo.SetIgnore(matcher)
+	vRepo.SetSecrets(repo.SecretScanner())
+	vRepo.SetEncryption(repo.Keyring())
+
+	return repo, vRepo
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// runRestore writes a saved version of a file back into the worktree
+func runRestore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	version := flags.String("version", "", "version to restore, by version number or commit hash")
+	at := flags.String("at", "", "restore the version saved at this time, e.g. \"2h ago\" or \"2024-05-01 14:32\"")
+	force := flags.Bool("force", false, "overwrite unsaved changes after saving them as a new version")
+	files := parseInterspersed(flags, args)
+
+	if len(files) != 1 || (*version != "" && *at != "") {
+		fmt.Println("Usage: ctrls restore <file> [--version <version|hash> | --at <time>] [--force]")
+		os.Exit(1)
+	}
+	file := filepath.ToSlash(filepath.Clean(files[0]))
+
+	repo, vRepo := openRepositories()
+
+	target, err := selectVersion(vRepo, file, *version, *at)
+	if err != nil {
+		fmt.Println("Error finding version:", err)
+		os.Exit(1)
+	}
+
+	rootPath, err := repo.GetRepoRoot()
+	if err != nil {
+		fmt.Println("You are not in the root of the Git repository.")
+		os.Exit(1)
+	}
+	dstPath, err := utils.ProjectPath(rootPath, file)
+	if err != nil {
+		fmt.Printf("Refusing to restore %s: %v\n", file, err)
+		os.Exit(1)
+	}
+
+	vRepo.BeginChangeset()
+	saveUnsaved(repo, vRepo, file, dstPath, *force)
+	commitChangeset(vRepo)
+
+	err = vRepo.WriteVersion(target, dstPath)
+	if err != nil {
+		fmt.Println("Error restoring file:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Restored %s to %s %s (%s)\n", file, versionName(target), target.Hash[:7], target.When.Local().Format("2006-01-02 15:04:05"))
+}
+
+// saveUnsaved makes sure the content of file at dstPath is saved before it
+// is overwritten, saving it as a new version with force and exiting
+// otherwise, or when it cannot be saved
+func saveUnsaved(repo, vRepo *repository.Repository, file, dstPath string, force bool) {
+	current, err := utils.ReadFileOrLink(dstPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Println("Error reading current file:", err)
+		os.Exit(1)
+	}
+	if err == nil {
+		saved, err := vRepo.IsSaved(file, current)
+		if err != nil {
+			fmt.Println("Error checking for unsaved changes:", err)
+			os.Exit(1)
+		}
+
+		if !saved {
+			if !force {
+				fmt.Printf("%s has changes that were never saved. Use --force to save them and restore anyway.\n", file)
+				os.Exit(1)
+			}
+
+			copied, err := repo.CopyFileToSubmodule(file)
+			if err != nil {
+				fmt.Println("Error copying file to submodule:", err)
+				os.Exit(1)
+			}
+			if !copied {
+				fmt.Printf("Could not save the current content of %s, restoring would lose it. Nothing was restored.\n", file)
+				os.Exit(1)
+			}
+			commit, err := vRepo.SaveFile(file)
+			if err != nil {
+				fmt.Println("Error saving current content:", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Saved current content of %s as %s\n", file, commit.String()[:7])
+		}
+	}
+}
+
+// commitChangeset ends the changeset begun for the versions saved before
+// overwriting unsaved changes
+func commitChangeset(vRepo *repository.Repository) {
+	changeset, err := vRepo.CommitChangeset()
+	if err != nil {
+		fmt.Println("Error committing changeset:", err)
+		os.Exit(1)
+	}
+	if !changeset.IsZero() {
+		fmt.Println("Changeset committed:", changeset)
+	}
+}
+
+// selectVersion finds the version of file named by version or saved at the
+// time described by at, defaulting to the latest version
+func selectVersion(vRepo *repository.Repository, file, version, at string) (repository.Version, error) {
+	versions, err := vRepo.FileVersions(file)
+	if err != nil {
+		return repository.Version{}, err
+	}
+
+	switch {
+	case version != "":
+		return repository.SelectVersion(versions, version)
+	case at != "":
+		t, err := utils.ParseTime(at, time.Now())
+		if err != nil {
+			return repository.Version{}, err
+		}
+		return repository.VersionAt(versions, t)
+	default:
+		return versions[0], nil
+	}
+}
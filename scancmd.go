@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"github.com/renatonmag/versionctrls-cli/pkg/secrets"
+)
+
+// runScan looks for secrets in the changed files, or in the given files and
+// directories, the way copies and snapshots do, and exits with 1 when it
+// finds any
+func runScan(args []string) {
+	flags := flag.NewFlagSet("scan", flag.ExitOnError)
+	all := flags.Bool("all", false, "scan every file in the worktree instead of the changed ones")
+	asJSON := flags.Bool("json", false, "print the findings as JSON")
+	paths := parseInterspersed(flags, args)
+
+	if *all && len(paths) > 0 {
+		fmt.Println("Usage: ctrls scan [<path|dir>... | --all] [--json]")
+		os.Exit(2)
+	}
+
+	repo, _ := openProject()
+
+	rootPath, err := repo.GetRepoRoot()
+	if err != nil {
+		fmt.Println("You are not in the root of the Git repository.")
+		os.Exit(1)
+	}
+
+	if *all {
+		paths = []string{"."}
+	}
+	files, err := scanTargets(repo, rootPath, paths)
+	if err != nil {
+		fmt.Println("Error listing files to scan:", err)
+		os.Exit(1)
+	}
+
+	scanner := repo.SecretScanner()
+	findings := []secrets.Finding{}
+	scanned := 0
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(rootPath, filepath.FromSlash(file)))
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		findings = append(findings, scanner.Scan(file, content)...)
+		scanned++
+	}
+
+	if *asJSON {
+		printJSON(findings)
+	} else {
+		printFindings(findings, scanned)
+	}
+	if len(findings) > 0 {
+		os.Exit(1)
+	}
+}
+
+// scanTargets returns the regular files to scan, slash separated and
+// relative to root. Without paths those are the changed files, otherwise
+// the files given and the ones below the directories given, leaving out
+// ignored files and the integration submodule.
+func scanTargets(repo *repository.Repository, root string, paths []string) ([]string, error) {
+	var files []string
+	if len(paths) == 0 {
+		changes, err := repo.FileChanges()
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range changes {
+			if change.Kind == repository.Deleted {
+				continue
+			}
+			info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(change.Path)))
+			if err == nil && info.Mode().IsRegular() {
+				files = append(files, change.Path)
+			}
+		}
+		return files, nil
+	}
+
+	submodule := filepath.Clean(repo.SubmodulePath())
+	for _, path := range paths {
+		start, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		err = filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			slashed := filepath.ToSlash(rel)
+
+			if d.IsDir() {
+				if rel != "." && (d.Name() == ".git" || rel == submodule || repo.IgnoreMatcher().Match(slashed, true)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() && !repo.Ignored(slashed) {
+				files = append(files, slashed)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// printFindings lists the findings with the fingerprints that allow them
+func printFindings(findings []secrets.Finding, scanned int) {
+	if len(findings) == 0 {
+		fmt.Printf("No secrets found in %d files.\n", scanned)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LOCATION\tRULE\tSECRET\tFINGERPRINT")
+	files := map[string]bool{}
+	for _, finding := range findings {
+		files[finding.Path] = true
+		fmt.Fprintf(w, "%s:%d:%d\t%s\t%s\t%s\n", finding.Path, finding.Line, finding.Column, finding.Rule, finding.Preview, finding.Fingerprint)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d possible secrets in %d of %d files. To version one anyway, add its fingerprint or the file to %s.\n",
+		len(findings), len(files), scanned, secrets.AllowlistFile)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runShow prints the content of a saved version of a file, decrypted
+func runShow(args []string) {
+	flags := flag.NewFlagSet("show", flag.ExitOnError)
+	version := flags.String("version", "", "version to show, by version number or commit hash")
+	at := flags.String("at", "", "show the version saved at this time, e.g. \"2h ago\" or \"2024-05-01 14:32\"")
+	files := parseInterspersed(flags, args)
+
+	if len(files) != 1 || (*version != "" && *at != "") {
+		fmt.Println("Usage: ctrls show <file> [--version <version|hash> | --at <time>]")
+		os.Exit(1)
+	}
+	file := filepath.ToSlash(filepath.Clean(files[0]))
+
+	_, vRepo := openRepositories()
+
+	target, err := selectVersion(vRepo, file, *version, *at)
+	if err != nil {
+		fmt.Println("Error finding version:", err)
+		os.Exit(1)
+	}
+
+	content, err := vRepo.ReadVersion(target)
+	if err != nil {
+		fmt.Println("Error reading version:", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(content)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"github.com/renatonmag/versionctrls-cli/pkg/utils"
+)
+
+// runStatus shows which changed files are saved and which versions only
+// exist on this machine
+func runStatus() {
+	repo, vRepo := openRepositories()
+
+	rootPath, err := repo.GetRepoRoot()
+	if err != nil {
+		fmt.Println("You are not in the root of the Git repository.")
+		os.Exit(1)
+	}
+
+	changes, err := repo.FileChanges()
+	if err != nil {
+		fmt.Println("Error getting changed files:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Changed files:")
+	if len(changes) == 0 {
+		fmt.Println("  none")
+	}
+	for _, change := range changes {
+		name := change.Path
+		if change.Kind == repository.Renamed {
+			name = change.From + " -> " + change.Path
+		}
+
+		state := "saved"
+		content, err := utils.ReadFileOrLink(filepath.Join(rootPath, change.Path))
+		if err != nil {
+			state = "deleted"
+		} else if saved, err := vRepo.IsSaved(change.Path, content); err != nil || !saved {
+			state = "not saved"
+		}
+		fmt.Printf("  %-10s %s\n", state, name)
+	}
+
+	entries, err := vRepo.Outbox()
+	if err != nil {
+		fmt.Println("Error reading the push queue:", err)
+		os.Exit(1)
+	}
+	printOutbox(entries, time.Now())
+}
+
+// printOutbox lists the versions waiting to be pushed and when they will be
+// tried again
+func printOutbox(entries []repository.OutboxEntry, now time.Time) {
+	versions := 0
+	for _, entry := range entries {
+		versions += entry.Versions
+	}
+
+	fmt.Println("\nNot pushed yet:")
+	if versions == 0 {
+		fmt.Println("  every version is safe on the remote")
+		return
+	}
+
+	fmt.Printf("  %d %s of %d %s only on this machine\n",
+		versions, plural(versions, "version"), len(entries), plural(len(entries), "file"))
+	for _, entry := range entries {
+		line := fmt.Sprintf("  %-4d %s", entry.Versions, entry.Path)
+		if entry.LastError != "" {
+			retry := "retrying now"
+			if !entry.Due(now) {
+				retry = "retry in " + entry.NextAttempt.Sub(now).Round(time.Second).String()
+			}
+			line += fmt.Sprintf(" (%d failed %s, %s: %s)",
+				entry.Attempts, plural(entry.Attempts, "attempt"), retry, entry.LastError)
+		}
+		fmt.Println(line)
+	}
+	fmt.Println("\nRun ctrls push to upload them now.")
+}
+
+// plural returns word, with an s unless n is one
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
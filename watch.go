@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/renatonmag/versionctrls-cli/pkg/config"
+	"github.com/renatonmag/versionctrls-cli/pkg/ignore"
+	"github.com/renatonmag/versionctrls-cli/pkg/repository"
+	"github.com/renatonmag/versionctrls-cli/pkg/watcher"
+)
+
+// retryInterval is how often the watcher looks for queued versions to push
+const retryInterval = 30 * time.Second
+
+// runWatch snapshots every file in the repository as soon as it is saved
+func runWatch(args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	debounce := flags.Duration("debounce", 500*time.Millisecond, "how long a file must stay untouched before it is captured")
+	flags.Parse(args)
+
+	repo, vRepo := openRepositories()
+
+	rootPath, err := repo.GetRepoRoot()
+	if err != nil {
+		fmt.Println("You are not in the root of the Git repository.")
+		return
+	}
+
+	w, err := watcher.New(rootPath, *debounce, repo.IgnoreMatcher().Match, repo.SubmodulePath())
+	if err != nil {
+		log.Fatalf("Error starting watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Queued versions are pushed in the background after every batch and
+	// retried every retryInterval, without holding up saves. Only the outbox
+	// is shared with them, and the repository guards it.
+	var mu sync.Mutex
+	pushNow := make(chan struct{}, 1)
+	if enabled, auth := autoPushAuth(vRepo); enabled {
+		go func() {
+			ticker := time.NewTicker(retryInterval)
+			defer ticker.Stop()
+			for {
+				mu.Lock()
+				pushing := *vRepo
+				mu.Unlock()
+				autoPush(&pushing, auth)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				case <-pushNow:
+				}
+			}
+		}()
+	}
+
+	log.Printf("Watching %s for saves (ctrl+c to stop)\n", rootPath)
+	w.Run(ctx, func(batch watcher.Batch) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if changesIgnoreRules(batch) {
+			reloadIgnore(repo, vRepo, w, rootPath)
+			// Files that are no longer ignored may have changes to save
+			batch.Rescan = true
+		} else if batch.Rescan {
+			log.Println("Some file events were missed, looking for changes in the whole worktree")
+		}
+
+		if saveBatch(repo, vRepo, batch) {
+			select {
+			case pushNow <- struct{}{}:
+			default:
+			}
+		}
+	}, func(err error) {
+		log.Printf("Error watching files: %v\n", err)
+	})
+}
+
+// changesIgnoreRules reports whether a batch touched an ignore file
+func changesIgnoreRules(batch watcher.Batch) bool {
+	for _, file := range append(append([]string{}, batch.Saved...), batch.Removed...) {
+		name := filepath.Base(file)
+		if name == ignore.File || name == ".gitignore" {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadIgnore reads the ignore rules of the project again and hands them
+// to both repositories and the watcher
+func reloadIgnore(repo, vRepo *repository.Repository, w *watcher.Watcher, rootPath string) {
+	cfg, err := config.Load(rootPath)
+	if err != nil {
+		log.Printf("Error reading configuration, keeping the old ignore rules: %v\n", err)
+		return
+	}
+	matcher, err := ignore.Load(rootPath, cfg.Ignore.Presets)
+	if err != nil {
+		log.Printf("Error reading ignore rules, keeping the old ones: %v\n", err)
+		return
+	}
+
+	repo.SetIgnore(matcher)
+	vRepo.SetIgnore(matcher)
+	if err := w.SetIgnored(matcher.Match); err != nil {
+		log.Printf("Error watching directories that are no longer ignored: %v\n", err)
+	}
+	log.Println("Reloaded the ignore rules")
+}
+
+// saveBatch records the files of a batch in the integration repository as
+// one changeset, reporting whether anything was recorded. A batch to rescan
+// records every file changed in the worktree.
+func saveBatch(repo, vRepo *repository.Repository, batch watcher.Batch) bool {
+	changes, err := repo.FileChanges()
+	if err != nil {
+		log.Printf("Error getting changed files: %v\n", err)
+		return false
+	}
+	if batch.Rescan {
+		for _, change := range changes {
+			if change.Kind == repository.Deleted {
+				batch.Removed = append(batch.Removed, change.Path)
+			} else {
+				batch.Saved = append(batch.Saved, change.Path)
+			}
+		}
+	}
+
+	id := vRepo.BeginChangeset()
+	// Both paths of a rename are in the batch, and it is recorded once
+	recorded := map[repository.FileChange]bool{}
+	saved := false
+	for _, file := range batch.Saved {
+		saved = saveChange(repo, vRepo, changes, recorded, filepath.ToSlash(file), false) || saved
+	}
+	for _, file := range batch.Removed {
+		saved = saveChange(repo, vRepo, changes, recorded, filepath.ToSlash(file), true) || saved
+	}
+
+	changeset, err := vRepo.CommitChangeset()
+	if err != nil {
+		log.Printf("Error recording changeset: %v\n", err)
+	} else if !changeset.IsZero() {
+		log.Printf("Recorded changeset %s\n", id)
+	}
+	return saved
+}
+
+// saveChange records what happened to file in the integration repository,
+// reporting whether a version was made. Deletions and renames are told
+// apart by changes, the status of the repository, and removals of files it
+// does not know, like editor temp files, are ignored. Changes already in
+// recorded are skipped.
+func saveChange(repo, vRepo *repository.Repository, changes []repository.FileChange, recorded map[repository.FileChange]bool, file string, removed bool) bool {
+	if repo.Ignored(file) {
+		return false
+	}
+
+	change, found := repository.FileChange{Path: file, Kind: repository.Modified}, false
+	for _, c := range changes {
+		if c.Path == file || c.From == file {
+			change, found = c, true
+			break
+		}
+	}
+	if removed && !found || recorded[change] {
+		return false
+	}
+	recorded[change] = true
+
+	commit, err := repo.PropagateChange(vRepo, change)
+	if err != nil {
+		log.Printf("Error saving %s: %v\n", file, err)
+		return false
+	}
+	if commit.IsZero() {
+		return false
+	}
+
+	switch change.Kind {
+	case repository.Deleted:
+		log.Printf("Recorded deletion of %s as %s\n", change.Path, commit.String()[:7])
+	case repository.Renamed:
+		log.Printf("Recorded rename of %s to %s as %s\n", change.From, change.Path, commit.String()[:7])
+	default:
+		log.Printf("Saved %s as %s\n", change.Path, commit.String()[:7])
+	}
+	return true
+}